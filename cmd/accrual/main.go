@@ -0,0 +1,118 @@
+// Command accrual is a local stand-in for the accrual system. It keeps its data in memory, or in
+// Postgres when a database URI is given.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/accrualsystem"
+	"github.com/tiunovvv/gophermart/internal/config"
+	"github.com/tiunovvv/gophermart/internal/logging"
+)
+
+type options struct {
+	runAddress      string
+	databaseURI     string
+	rateLimit       int
+	processInterval time.Duration
+	logLevel        string
+}
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseOptions reads flags, environment variables take precedence as in gophermart.
+func parseOptions(args []string) (options, error) {
+	opts := options{}
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.StringVar(&opts.runAddress, "a", "localhost:8000", "runAddress")
+	fs.StringVar(&opts.databaseURI, "d", "", "databaseDSN, empty keeps the data in memory")
+	fs.IntVar(&opts.rateLimit, "rate-limit", 0, "order requests per minute, 0 is unlimited")
+	fs.DurationVar(&opts.processInterval, "process-interval", time.Second, "time an order spends in each state")
+	fs.StringVar(&opts.logLevel, "log-level", "info", "log level")
+	if err := fs.Parse(args); err != nil {
+		return opts, fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	for flagName, env := range map[string]string{
+		"a":                "RUN_ADDRESS",
+		"d":                "DATABASE_URI",
+		"rate-limit":       "RATE_LIMIT",
+		"process-interval": "PROCESS_INTERVAL",
+		"log-level":        "LOG_LEVEL",
+	} {
+		if value, ok := os.LookupEnv(env); ok {
+			if err := fs.Set(flagName, value); err != nil {
+				return opts, fmt.Errorf("invalid value %q for %s: %w", value, env, err)
+			}
+		}
+	}
+
+	if opts.rateLimit < 0 {
+		return opts, fmt.Errorf("rate limit must not be negative, got %d", opts.rateLimit)
+	}
+	if opts.processInterval <= 0 {
+		return opts, fmt.Errorf("process interval must be positive, got %s", opts.processInterval)
+	}
+	return opts, nil
+}
+
+func run() error {
+	opts, err := parseOptions(os.Args[1:])
+	if err != nil {
+		return err
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancelCtx()
+
+	logger, err := logging.NewLogger(config.LogConfig{Level: opts.logLevel, Format: config.LogFormatConsole})
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger %w", err)
+	}
+	defer logger.Sync()
+	log := logger.Root().Sugar()
+
+	var storage accrualsystem.Storage = accrualsystem.NewMemoryStorage()
+	if opts.databaseURI != "" {
+		if storage, err = accrualsystem.NewPostgresStorage(ctx, opts.databaseURI); err != nil {
+			return fmt.Errorf("failed to initialize storage: %w", err)
+		}
+	}
+	defer storage.Close()
+
+	go accrualsystem.NewProcessor(storage, log, opts.processInterval).Start(ctx)
+
+	srv := &http.Server{
+		Addr:              opts.runAddress,
+		Handler:           accrualsystem.NewServer(storage, log, opts.rateLimit).Routes(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Errorf("an error occurred during server shutdown: %v", err)
+		}
+	}()
+
+	log.Infow("accrual system started", "address", opts.runAddress, "postgres", opts.databaseURI != "",
+		"rate_limit", opts.rateLimit)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("listen and server has failed: %w", err)
+	}
+	return nil
+}
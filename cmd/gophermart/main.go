@@ -9,13 +9,18 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/tiunovvv/gophermart/internal/accrual"
 	"github.com/tiunovvv/gophermart/internal/config"
 	"github.com/tiunovvv/gophermart/internal/database"
+	"github.com/tiunovvv/gophermart/internal/expiration"
 	"github.com/tiunovvv/gophermart/internal/handler"
+	"github.com/tiunovvv/gophermart/internal/health"
+	"github.com/tiunovvv/gophermart/internal/logging"
 	"github.com/tiunovvv/gophermart/internal/mart"
+	"github.com/tiunovvv/gophermart/internal/metrics"
 	"github.com/tiunovvv/gophermart/internal/server"
 	"go.uber.org/zap"
 )
@@ -26,56 +31,75 @@ func main() {
 	}
 }
 
-const (
-	timeoutServerShutdown = time.Second * 5
-	timeoutShutdown       = time.Second * 10
-)
+// shutdownMargin is added to the configured drain delay and shutdown timeout before the shutdown
+// watchdog gives up, it covers the dispatcher storing its last results and closing the DB.
+const shutdownMargin = time.Second * 10
 
 func run() error {
-	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancelCtx()
 
+	wg := &sync.WaitGroup{}
+	defer func() {
+		wg.Wait()
+	}()
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
 	context.AfterFunc(ctx, func() {
-		ctx, cancelCtx := context.WithTimeout(context.Background(), timeoutShutdown)
+		timeout := cfg.Server.DrainDelay + cfg.Server.ShutdownTimeout + shutdownMargin
+		ctx, cancelCtx := context.WithTimeout(context.Background(), timeout)
 		defer cancelCtx()
 
 		<-ctx.Done()
 		log.Fatal("failed to gracefully shutdown the service")
 	})
 
-	wg := &sync.WaitGroup{}
-	defer func() {
-		wg.Wait()
-	}()
-
-	cfg := config.GetConfig()
-
-	logger, err := zap.NewDevelopment()
+	logger, err := logging.NewLogger(cfg.Log)
 	if err != nil {
 		return fmt.Errorf("failed to initialize logger %w", err)
 	}
+	defer logger.Sync()
 
-	log := logger.Sugar()
+	log := logger.Root().Sugar()
 
-	db, err := database.NewDB(ctx, cfg.DatabaseDSN, log)
+	m := metrics.NewMetrics()
+
+	db, err := database.NewDB(ctx, cfg.DatabaseDSN, logger.Component(logging.DB), m)
 	if err != nil {
 		return fmt.Errorf("failed to initialize a new DB %w", err)
 	}
 
-	mart := mart.NewMart(db, log)
+	mart, err := mart.NewMart(cfg, db, log)
+	if err != nil {
+		return fmt.Errorf("failed to initialize mart: %w", err)
+	}
+
+	disp := accrual.NewDispatcher(cfg, mart, logger.Component(logging.Accrual), m, cfg.Accrual.WorkerCount)
+	dispDone := make(chan struct{})
+	go func() {
+		defer close(dispDone)
+		disp.Start(ctx)
+	}()
 
-	const workerCount = 3
-	disp := accrual.NewDispatcher(cfg, mart, log, workerCount)
-	go disp.Start(ctx)
+	if cfg.Expiration.Enabled {
+		go expiration.NewExpirer(cfg, mart, log, expiration.RealClock{}).Start(ctx)
+	}
 
-	watch(ctx, wg, db)
+	reloadOnSIGHUP(ctx, log, logger, disp)
 
-	h := handler.NewHandler(cfg, mart, log)
-	srv := server.InitServer(h, cfg, logger)
+	hc := health.NewChecker(db, disp, cfg.AccrualSystemAddress)
+
+	h := handler.NewHandler(cfg, mart, logger)
+	srv := server.InitServer(h, cfg, logger.Root(), m, hc)
 
 	componentsErrs := make(chan error, 1)
 
-	manageServer(ctx, wg, srv, componentsErrs)
+	srvDone := manageServer(ctx, wg, srv, cfg.Server, hc, componentsErrs)
+	watch(ctx, wg, db, dispDone, srvDone)
 
 	select {
 	case <-ctx.Done():
@@ -87,19 +111,70 @@ func run() error {
 	return nil
 }
 
-func watch(ctx context.Context, wg *sync.WaitGroup, db *database.DB) {
+// watch closes the DB on shutdown once the dispatcher has stored its last accrual results and the server
+// has finished the requests it was serving.
+func watch(ctx context.Context, wg *sync.WaitGroup, db *database.DB, dispDone, srvDone <-chan struct{}) {
 	wg.Add(1)
 	go func() {
 		defer log.Print("closed DB and stoped Dispatcher")
 		defer wg.Done()
 
 		<-ctx.Done()
+		<-dispDone
+		<-srvDone
 
 		db.Close()
 	}()
 }
 
-func manageServer(ctx context.Context, wg *sync.WaitGroup, srv *http.Server, errs chan<- error) {
+// reloadOnSIGHUP re-reads the config on SIGHUP and applies the fields that are safe to change at runtime:
+// log levels, accrual worker bounds and accrual rate limit. Other changes require a restart.
+func reloadOnSIGHUP(ctx context.Context, log *zap.SugaredLogger, logger *logging.Logger, disp *accrual.Dispatcher) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+			}
+
+			cfg, err := config.GetConfig()
+			if err != nil {
+				log.Errorf("failed to reload config, keeping the current one: %v", err)
+				continue
+			}
+
+			if err := logger.Apply(cfg.Log); err != nil {
+				log.Errorf("failed to reload log levels: %v", err)
+				continue
+			}
+			disp.SetWorkerBounds(cfg.Accrual.MinWorkers, cfg.Accrual.MaxWorkers)
+			disp.SetRateLimit(cfg.Accrual.RateLimit)
+
+			log.Infow("config reloaded",
+				"log_level", cfg.Log.Level,
+				"min_workers", cfg.Accrual.MinWorkers,
+				"max_workers", cfg.Accrual.MaxWorkers,
+				"rate_limit", cfg.Accrual.RateLimit,
+			)
+		}
+	}()
+}
+
+func manageServer(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	srv *http.Server,
+	serverCfg config.ServerConfig,
+	hc *health.Checker,
+	errs chan<- error,
+) <-chan struct{} {
+	done := make(chan struct{})
+
 	go func(errs chan<- error) {
 		if err := srv.ListenAndServe(); err != nil {
 			if errors.Is(err, http.ErrServerClosed) {
@@ -113,12 +188,19 @@ func manageServer(ctx context.Context, wg *sync.WaitGroup, srv *http.Server, err
 	go func() {
 		defer log.Print("server has been shutdown")
 		defer wg.Done()
+		defer close(done)
 		<-ctx.Done()
 
-		shutdownTimeoutCtx, cancelShutdownTimeoutCtx := context.WithTimeout(context.Background(), timeoutServerShutdown)
+		hc.SetShuttingDown()
+		// Give readiness probes time to see the failing /readyz before connections are refused.
+		time.Sleep(serverCfg.DrainDelay)
+
+		shutdownTimeoutCtx, cancelShutdownTimeoutCtx := context.WithTimeout(context.Background(), serverCfg.ShutdownTimeout)
 		defer cancelShutdownTimeoutCtx()
 		if err := srv.Shutdown(shutdownTimeoutCtx); err != nil {
 			log.Printf("an error occurred during server shutdown: %v", err)
 		}
 	}()
+
+	return done
 }
@@ -2,79 +2,282 @@ package accrual
 
 import (
 	"context"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
 	"github.com/tiunovvv/gophermart/internal/config"
-	"github.com/tiunovvv/gophermart/internal/mart"
+	"github.com/tiunovvv/gophermart/internal/metrics"
 	"github.com/tiunovvv/gophermart/internal/models"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
-type accrualError struct {
-	error
-	timeout int
-}
-
 type Dispatcher struct {
-	cfg         *config.Config
-	mart        *mart.Mart
-	log         *zap.SugaredLogger
-	ordersChan  chan models.OrderWithTime
-	errorChan   chan accrualError
-	workerCount int
+	cfg      *config.Config
+	mart     Mart
+	log      *zap.SugaredLogger
+	metrics  *metrics.Metrics
+	client   *accrualclient.Client
+	limiter  *rate.Limiter
+	inFlight *inFlight
+	results  *results
+	// registrar registers orders uploaded with goods before they are polled.
+	registrar *registrar
+	// priorityChan is the lane of NEW orders, workers take from it before ordersChan.
+	priorityChan chan models.OrderWithTime
+	ordersChan   chan models.OrderWithTime
+	pauseChan    chan time.Duration
+	wakeChan     chan struct{}
+	drainedChan  chan struct{}
+	resizeChan   chan struct{}
+	workerCount  atomic.Int64
+	minWorkers   atomic.Int64
+	maxWorkers   atomic.Int64
+	stats        scaleStats
+	heartbeat    atomic.Int64
+	workers      []context.CancelFunc
+	nextID       int
+	wg           sync.WaitGroup
+	// backlog is set when the last poll left due orders behind.
+	backlog bool
 }
 
-func NewDispatcher(cfg *config.Config, mart *mart.Mart, log *zap.SugaredLogger, workerCount int) *Dispatcher {
+const staleHeartbeat = 30 * time.Second
+
+func NewDispatcher(
+	cfg *config.Config,
+	mart Mart,
+	log *zap.SugaredLogger,
+	m *metrics.Metrics,
+	workerCount int,
+) *Dispatcher {
+	breaker := accrualclient.NewBreaker(cfg.Accrual.BreakerThreshold, cfg.Accrual.BreakerCoolDown)
+	breaker.OnStateChange = func(from, to accrualclient.BreakerState) {
+		log.Warnw("accrual circuit breaker state changed", "from", from, "to", to)
+	}
+
+	inFlight := newInFlight()
 	dispatcher := &Dispatcher{
-		cfg:         cfg,
+		cfg:      cfg,
+		mart:     mart,
+		log:      log,
+		metrics:  m,
+		client:   accrualclient.New(cfg.AccrualSystemAddress, &http.Client{Timeout: cfg.Accrual.RequestTimeout}, breaker),
+		limiter:  rate.NewLimiter(rateLimit(cfg.Accrual.RateLimit), 1),
+		inFlight: inFlight,
+		results: newResults(mart, log, inFlight, cfg.Accrual.ResultBatchSize,
+			cfg.Accrual.ResultFlushInterval),
+		priorityChan: make(chan models.OrderWithTime, cfg.Accrual.BatchSize),
+		ordersChan:   make(chan models.OrderWithTime, cfg.Accrual.BatchSize),
+		pauseChan:    make(chan time.Duration, 1),
+		wakeChan:     make(chan struct{}, 1),
+		drainedChan:  make(chan struct{}, 1),
+		resizeChan:   make(chan struct{}, 1),
+	}
+	dispatcher.registrar = &registrar{
 		mart:        mart,
 		log:         log,
-		workerCount: workerCount,
+		client:      dispatcher.client,
+		limiter:     dispatcher.limiter,
+		maxAttempts: cfg.Accrual.RegisterAttempts,
+		interval:    cfg.Accrual.PollInterval,
+		wakeChan:    make(chan struct{}, 1),
+		registered:  dispatcher.wakeChan,
+		done:        make(chan struct{}),
 	}
+	dispatcher.minWorkers.Store(int64(cfg.Accrual.MinWorkers))
+	dispatcher.maxWorkers.Store(int64(cfg.Accrual.MaxWorkers))
+	dispatcher.workerCount.Store(dispatcher.clampWorkers(workerCount))
+
+	m.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "gophermart",
+		Subsystem: "accrual",
+		Name:      "queue_depth",
+		Help:      "Orders waiting in the dispatcher queue.",
+	}, func() float64 {
+		return float64(len(dispatcher.priorityChan) + len(dispatcher.ordersChan))
+	}))
+	m.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "gophermart",
+		Subsystem: "accrual",
+		Name:      "breaker_state",
+		Help:      "State of the accrual circuit breaker: 0 closed, 1 half-open, 2 open.",
+	}, func() float64 {
+		switch dispatcher.BreakerState() {
+		case accrualclient.BreakerOpen:
+			return 2
+		case accrualclient.BreakerHalfOpen:
+			return 1
+		default:
+			return 0
+		}
+	}))
 
 	return dispatcher
 }
 
+func rateLimit(perSecond float64) rate.Limit {
+	if perSecond == 0 {
+		return rate.Inf
+	}
+	return rate.Limit(perSecond)
+}
+
+// SetRateLimit changes the number of accrual requests per second, 0 removes the limit.
+func (d *Dispatcher) SetRateLimit(perSecond float64) {
+	d.limiter.SetLimit(rateLimit(perSecond))
+}
+
+// Start queues orders for the workers whenever the database announces new orders and, as a fallback
+// for missed notifications and for orders still in PROCESSING, every poll interval. It returns once
+// the workers have stopped and their results are stored.
 func (d *Dispatcher) Start(ctx context.Context) {
-	d.ordersChan = make(chan models.OrderWithTime, d.workerCount)
-	d.errorChan = make(chan accrualError)
-	defer close(d.ordersChan)
-	defer close(d.errorChan)
+	go d.results.run(ctx)
+	go d.registrar.run(ctx)
+	defer func() {
+		d.wg.Wait()
+		close(d.results.input)
+		<-d.results.done
+		<-d.registrar.done
+	}()
 
-	var wg sync.WaitGroup
-	for i := 1; i <= d.workerCount; i++ {
-		worker := &Worker{
-			ID:         i,
-			OrdersChan: d.ordersChan,
-			ErrorChan:  d.errorChan,
+	d.resize(ctx)
+
+	go d.mart.ListenNewOrders(ctx, d.wakeChan)
+
+	ticker := time.NewTicker(d.cfg.Accrual.PollInterval)
+	defer ticker.Stop()
+
+	scaleTicker := time.NewTicker(d.cfg.Accrual.ScaleInterval)
+	defer scaleTicker.Stop()
+
+	poll := true
+	for {
+		d.heartbeat.Store(time.Now().UnixNano())
+		if poll {
+			d.poll(ctx)
 		}
-		wg.Add(1)
-		go worker.Start(ctx, &wg, d.cfg, d.log, d.mart)
-	}
+		poll = true
 
-	go func() {
-		for {
-			orders, err := d.mart.GetNewOrders(ctx)
-			if err != nil {
-				d.log.Errorf("failed to get new orders: %v", err)
-				continue
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.resizeChan:
+			d.resize(ctx)
+		case pause := <-d.pauseChan:
+			d.log.Errorf("pausing workers for %s", pause)
+			if !d.pause(ctx, pause) {
+				return
 			}
+		case <-d.wakeChan:
+			d.registrar.nudge()
+		case <-d.drainedChan:
+			poll = d.backlog
+		case <-scaleTicker.C:
+			d.autoscale(ctx)
+			poll = false
+		case <-ticker.C:
+		}
+	}
+}
 
-			for _, order := range orders {
-				d.ordersChan <- order
-			}
+// pause stops polling for the given duration and reports false if ctx is done meanwhile. The heartbeat
+// keeps going, a paused dispatcher is alive.
+func (d *Dispatcher) pause(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	ticker := time.NewTicker(d.cfg.Accrual.PollInterval)
+	defer ticker.Stop()
 
-			select {
-			case err := <-d.errorChan:
-				d.log.Errorf("pausing workers for %s seconds\n", err.timeout)
-				pauseCtx, cancel := context.WithTimeout(ctx, time.Duration(err.timeout)*time.Second)
-				pauseCtx.Done()
-				cancel()
-			default:
-			}
-			time.Sleep(time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return true
+		case <-ticker.C:
+			d.heartbeat.Store(time.Now().UnixNano())
 		}
-	}()
-	wg.Wait()
+	}
+}
+
+// poll queues a fair batch of due orders without blocking. Orders that do not fit into a full lane are
+// left for the next poll, which runs as soon as workers drain both lanes. Nothing is queued while the
+// circuit breaker is open.
+func (d *Dispatcher) poll(ctx context.Context) {
+	if d.BreakerState() == accrualclient.BreakerOpen {
+		return
+	}
+
+	orders, err := d.mart.GetNewOrders(ctx, d.inFlight.Numbers())
+	if err != nil {
+		d.log.Errorf("failed to get new orders: %v", err)
+		return
+	}
+
+	d.backlog = len(orders) == d.cfg.Accrual.BatchSize
+	for _, order := range orders {
+		if !d.inFlight.Add(order.Number) {
+			continue
+		}
+		lane := d.ordersChan
+		if order.Status == models.OrderNew {
+			lane = d.priorityChan
+		}
+		select {
+		case lane <- order:
+		default:
+			d.inFlight.Done(order.Number)
+			d.backlog = true
+		}
+	}
+}
+
+func (d *Dispatcher) resize(ctx context.Context) {
+	target := int(d.workerCount.Load())
+
+	for len(d.workers) < target {
+		d.nextID++
+		workerCtx, cancel := context.WithCancel(ctx)
+		worker := &Worker{
+			ID:           d.nextID,
+			PriorityChan: d.priorityChan,
+			OrdersChan:   d.ordersChan,
+			PauseChan:    d.pauseChan,
+			DrainedChan:  d.drainedChan,
+			InFlight:     d.inFlight,
+			Client:       d.client,
+			Limiter:      d.limiter,
+			Metrics:      d.metrics,
+			Stats:        &d.stats,
+			Results:      d.results.input,
+		}
+		d.workers = append(d.workers, cancel)
+		d.wg.Add(1)
+		go worker.Start(workerCtx, &d.wg, d.log)
+	}
+
+	for len(d.workers) > target {
+		last := len(d.workers) - 1
+		d.workers[last]()
+		d.workers = d.workers[:last]
+	}
+
+	d.log.Infof("accrual worker pool size is %d", len(d.workers))
+}
+
+// BreakerState returns the state of the accrual circuit breaker.
+func (d *Dispatcher) BreakerState() accrualclient.BreakerState {
+	return d.client.BreakerState()
+}
+
+// Alive reports whether the polling loop has made progress recently.
+func (d *Dispatcher) Alive() bool {
+	last := d.heartbeat.Load()
+	stale := max(staleHeartbeat, 3*d.cfg.Accrual.PollInterval)
+	return last != 0 && time.Since(time.Unix(0, last)) < stale
 }
@@ -0,0 +1,189 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+	"github.com/tiunovvv/gophermart/internal/config"
+	"github.com/tiunovvv/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+func TestDispatcherPauseKeepsHeartbeat(t *testing.T) {
+	tests := []struct {
+		name     string
+		pause    time.Duration
+		cancel   bool
+		want     bool
+		wantBeat bool
+	}{
+		{name: "pause runs out", pause: 100 * time.Millisecond, want: true, wantBeat: true},
+		{name: "context canceled", pause: time.Hour, cancel: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Dispatcher{cfg: &config.Config{Accrual: config.AccrualConfig{PollInterval: 10 * time.Millisecond}}}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			start := time.Now()
+			if got := d.pause(ctx, tt.pause); got != tt.want {
+				t.Fatalf("pause() = %v, want %v", got, tt.want)
+			}
+			if tt.want && time.Since(start) < tt.pause {
+				t.Errorf("pause returned after %s, want at least %s", time.Since(start), tt.pause)
+			}
+			if beat := d.heartbeat.Load() > start.UnixNano(); beat != tt.wantBeat {
+				t.Errorf("heartbeat updated during the pause: %v, want %v", beat, tt.wantBeat)
+			}
+		})
+	}
+}
+
+func TestDispatcherPoll(t *testing.T) {
+	order := func(number string, status models.OrderStatus) models.OrderWithTime {
+		return models.OrderWithTime{Number: number, Status: status}
+	}
+
+	tests := []struct {
+		name         string
+		orders       []models.OrderWithTime
+		inFlight     []string
+		laneSize     int
+		batchSize    int
+		breakerOpen  bool
+		wantPolled   bool
+		wantExclude  []string
+		wantPriority []string
+		wantOrders   []string
+		wantInFlight []string
+		wantBacklog  bool
+	}{
+		{
+			name: "NEW orders take the priority lane",
+			orders: []models.OrderWithTime{
+				order("1", models.OrderNew), order("2", models.OrderProcessing), order("3", models.OrderNew),
+			},
+			wantPolled:   true,
+			wantPriority: []string{"1", "3"},
+			wantOrders:   []string{"2"},
+			wantInFlight: []string{"1", "2", "3"},
+		},
+		{
+			name:         "orders in flight are excluded and not queued twice",
+			orders:       []models.OrderWithTime{order("1", models.OrderNew), order("2", models.OrderNew)},
+			inFlight:     []string{"2"},
+			wantPolled:   true,
+			wantExclude:  []string{"2"},
+			wantPriority: []string{"1"},
+			wantInFlight: []string{"1", "2"},
+		},
+		{
+			name:         "full lane leaves the rest for the next poll",
+			orders:       []models.OrderWithTime{order("1", models.OrderNew), order("2", models.OrderNew)},
+			laneSize:     1,
+			wantPolled:   true,
+			wantPriority: []string{"1"},
+			wantInFlight: []string{"1"},
+			wantBacklog:  true,
+		},
+		{
+			name: "full batch means more orders are due",
+			orders: []models.OrderWithTime{
+				order("1", models.OrderProcessing), order("2", models.OrderProcessing),
+			},
+			batchSize:    2,
+			wantPolled:   true,
+			wantOrders:   []string{"1", "2"},
+			wantInFlight: []string{"1", "2"},
+			wantBacklog:  true,
+		},
+		{
+			name:        "open breaker skips the poll",
+			orders:      []models.OrderWithTime{order("1", models.OrderNew)},
+			breakerOpen: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			laneSize, batchSize := 10, 10
+			if tt.laneSize > 0 {
+				laneSize = tt.laneSize
+			}
+			if tt.batchSize > 0 {
+				batchSize = tt.batchSize
+			}
+			mart := &fakeMart{newOrders: tt.orders}
+			d := &Dispatcher{
+				cfg:          &config.Config{Accrual: config.AccrualConfig{BatchSize: batchSize}},
+				mart:         mart,
+				log:          zap.NewNop().Sugar(),
+				client:       newTestClient(t, tt.breakerOpen),
+				inFlight:     newInFlight(),
+				priorityChan: make(chan models.OrderWithTime, laneSize),
+				ordersChan:   make(chan models.OrderWithTime, laneSize),
+			}
+			for _, number := range tt.inFlight {
+				d.inFlight.Add(number)
+			}
+
+			d.poll(context.Background())
+
+			if mart.polled != tt.wantPolled {
+				t.Fatalf("polled = %v, want %v", mart.polled, tt.wantPolled)
+			}
+			if !slices.Equal(mart.exclude, tt.wantExclude) {
+				t.Errorf("excluded %v, want %v", mart.exclude, tt.wantExclude)
+			}
+			if got := drain(d.priorityChan); !slices.Equal(got, tt.wantPriority) {
+				t.Errorf("priority lane = %v, want %v", got, tt.wantPriority)
+			}
+			if got := drain(d.ordersChan); !slices.Equal(got, tt.wantOrders) {
+				t.Errorf("orders lane = %v, want %v", got, tt.wantOrders)
+			}
+			inFlight := d.inFlight.Numbers()
+			slices.Sort(inFlight)
+			if !slices.Equal(inFlight, tt.wantInFlight) {
+				t.Errorf("in flight = %v, want %v", inFlight, tt.wantInFlight)
+			}
+			if d.backlog != tt.wantBacklog {
+				t.Errorf("backlog = %v, want %v", d.backlog, tt.wantBacklog)
+			}
+		})
+	}
+}
+
+// newTestClient returns an accrual client whose breaker is open if open is set.
+func newTestClient(t *testing.T, open bool) *accrualclient.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := accrualclient.New(srv.URL, srv.Client(), accrualclient.NewBreaker(1, time.Hour))
+	if open {
+		if _, err := client.GetOrder(context.Background(), "1"); err == nil {
+			t.Fatal("failing accrual request succeeded")
+		}
+	}
+	return client
+}
+
+func drain(lane chan models.OrderWithTime) []string {
+	var numbers []string
+	for len(lane) > 0 {
+		numbers = append(numbers, (<-lane).Number)
+	}
+	return numbers
+}
@@ -2,100 +2,136 @@ package accrual
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
-	"net/http"
-	"net/url"
-	"strconv"
 	"sync"
+	"time"
 
-	"github.com/tiunovvv/gophermart/internal/config"
-	"github.com/tiunovvv/gophermart/internal/mart"
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+	"github.com/tiunovvv/gophermart/internal/metrics"
 	"github.com/tiunovvv/gophermart/internal/models"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
 type Worker struct {
-	OrdersChan chan models.OrderWithTime
-	ErrorChan  chan accrualError
-	ID         int
+	PriorityChan chan models.OrderWithTime
+	OrdersChan   chan models.OrderWithTime
+	PauseChan    chan time.Duration
+	// DrainedChan tells the dispatcher that both lanes are empty.
+	DrainedChan chan struct{}
+	InFlight    *inFlight
+	Client      *accrualclient.Client
+	Limiter     *rate.Limiter
+	Metrics     *metrics.Metrics
+	Stats       *scaleStats
+	// Results receives the updates to store, see results.
+	Results chan<- models.Order
+	ID      int
 }
 
 func (w *Worker) Start(
 	ctx context.Context,
 	wg *sync.WaitGroup,
-	cfg *config.Config,
 	log *zap.SugaredLogger,
-	mart *mart.Mart,
 ) {
 	defer wg.Done()
-	for order := range w.OrdersChan {
-		order, err := w.getOrder(log, cfg.AccrualSystemAddress, order.Number)
 
-		if errors.Is(err.error, errTooManyRequests) {
-			log.Errorf("failed get info about order %s from accrual: %w", order.Order, err.error)
-			w.ErrorChan <- err
-			return
-		}
+	w.Metrics.WorkersActive.Inc()
+	defer w.Metrics.WorkersActive.Dec()
 
-		if err.error != nil {
-			log.Errorf("failed get info about order %s from accrual: %w", order.Order, err.error)
+	for {
+		order, ok := w.next(ctx)
+		if !ok {
 			return
 		}
+		if !w.process(ctx, log, order) {
+			w.InFlight.Done(order.Number)
+		}
 
-		if err := mart.UpdateOrderAccrual(ctx, order); err != nil {
-			log.Errorf("failed to update order %s", order.Order)
-			return
+		if len(w.PriorityChan) == 0 && len(w.OrdersChan) == 0 {
+			select {
+			case w.DrainedChan <- struct{}{}:
+			default:
+			}
 		}
 	}
 }
 
-func (w *Worker) getOrder(log *zap.SugaredLogger, address string, number string) (models.Order, accrualError) {
-	var order models.Order
-	url, err := url.JoinPath(address, "/api/orders/", number)
-	if err != nil {
-		return order, accrualError{error: fmt.Errorf("failed to join path: %w", err), timeout: 0}
+// next takes a NEW order if there is one and otherwise waits for an order in either lane.
+func (w *Worker) next(ctx context.Context) (models.OrderWithTime, bool) {
+	select {
+	case order := <-w.PriorityChan:
+		return order, true
+	default:
 	}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return order, accrualError{error: fmt.Errorf("failed to get request from accural: %w", err), timeout: 0}
+	select {
+	case <-ctx.Done():
+		return models.OrderWithTime{}, false
+	case order := <-w.PriorityChan:
+		return order, true
+	case order := <-w.OrdersChan:
+		return order, true
 	}
+}
 
-	if resp.StatusCode == http.StatusNoContent {
-		return order, accrualError{error: errOrderNotRegistered, timeout: 0}
+// process asks the accrual system about the order and reports whether an update was handed to Results,
+// which then owns the order until the update is stored.
+func (w *Worker) process(
+	ctx context.Context,
+	log *zap.SugaredLogger,
+	orderWithTime models.OrderWithTime,
+) bool {
+	if err := w.Limiter.Wait(ctx); err != nil {
+		return false
 	}
 
-	if resp.StatusCode == http.StatusInternalServerError {
-		return order, accrualError{error: errAccrualServerError, timeout: 0}
-	}
+	start := time.Now()
+	order, err := w.Client.GetOrder(ctx, orderWithTime.Number)
+	w.observe(start, accrualclient.Outcome(err))
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		retryAfter := resp.Header.Get("Retry-After")
-		timeout, err := strconv.Atoi(retryAfter)
-		if err != nil {
-			return order, accrualError{error: fmt.Errorf("failed to convert timeout: %w", err), timeout: 0}
+	var openErr *accrualclient.CircuitOpenError
+	if errors.As(err, &openErr) {
+		select {
+		case w.PauseChan <- openErr.RetryAfter:
+		default:
 		}
-
-		return order, accrualError{error: errTooManyRequests, timeout: timeout}
+		return false
 	}
 
-	defer func() {
-		err := resp.Body.Close()
-		if err != nil {
-			log.Errorf("failed to close body: %w", err)
+	var rateErr *accrualclient.RateLimitError
+	w.Stats.observe(time.Since(start), errors.As(err, &rateErr))
+	if rateErr != nil {
+		log.Errorf("failed get info about order %s from accrual: %v", orderWithTime.Number, err)
+		select {
+		case w.PauseChan <- rateErr.RetryAfter:
+		default:
 		}
-	}()
+		return false
+	}
+
+	if errors.Is(err, accrualclient.ErrNotRegistered) {
+		log.Infof("order %s is not registered in accrual yet", orderWithTime.Number)
+		return false
+	}
 
-	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return order, accrualError{error: fmt.Errorf("failed to read body: %w", err), timeout: 0}
+		log.Errorf("failed get info about order %s from accrual: %v", orderWithTime.Number, err)
+		return false
 	}
 
-	if err := json.Unmarshal(body, &order); err != nil {
-		return order, accrualError{error: fmt.Errorf("failed to unmarshal body: %w", err), timeout: 0}
+	status, ok := order.Status.OrderStatus()
+	if !ok {
+		log.Errorf("unknown accrual status %q of order %s", order.Status, order.Order)
+		return false
 	}
-	return order, accrualError{error: nil, timeout: 0}
+
+	// The results are read until every worker has stopped, so the send never blocks for good.
+	w.Results <- models.Order{Order: order.Order, Status: status, Accrual: order.Accrual}
+	return true
+}
+
+func (w *Worker) observe(start time.Time, outcome string) {
+	w.Metrics.AccrualDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	w.Metrics.AccrualRequests.WithLabelValues(outcome).Inc()
 }
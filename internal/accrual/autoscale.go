@@ -0,0 +1,124 @@
+package accrual
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	scaleReasonRateLimited = "rate_limited"
+	scaleReasonBacklog     = "backlog"
+	scaleReasonIdle        = "idle"
+)
+
+// scaleStats collects accrual request latency and 429 responses between two autoscaling decisions.
+type scaleStats struct {
+	mu        sync.Mutex
+	total     time.Duration
+	requests  int
+	throttled int
+}
+
+func (s *scaleStats) observe(latency time.Duration, throttled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total += latency
+	s.requests++
+	if throttled {
+		s.throttled++
+	}
+}
+
+// reset returns the average latency and the number of 429 responses since the previous reset.
+func (s *scaleStats) reset() (time.Duration, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var average time.Duration
+	if s.requests > 0 {
+		average = s.total / time.Duration(s.requests)
+	}
+	throttled := s.throttled
+	s.total, s.requests, s.throttled = 0, 0, 0
+	return average, throttled
+}
+
+// SetWorkerBounds changes the range the pool is autoscaled in and resizes a pool outside of it. The
+// autoscaler owns the size within the bounds, the configured worker count is only the initial size.
+func (d *Dispatcher) SetWorkerBounds(minWorkers, maxWorkers int) {
+	d.minWorkers.Store(int64(minWorkers))
+	d.maxWorkers.Store(int64(maxWorkers))
+
+	current := d.workerCount.Load()
+	if clamped := d.clampWorkers(int(current)); clamped != current {
+		d.workerCount.Store(clamped)
+		select {
+		case d.resizeChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (d *Dispatcher) clampWorkers(n int) int64 {
+	return max(d.minWorkers.Load(), min(int64(n), d.maxWorkers.Load()))
+}
+
+// autoscale resizes the pool to the number of workers needed to send the backlog within one scale
+// interval at the observed latency. Any 429 halves the pool instead.
+func (d *Dispatcher) autoscale(ctx context.Context) {
+	backlog, err := d.mart.CountDueOrders(ctx)
+	if err != nil {
+		d.log.Errorf("failed to count due orders: %v", err)
+		return
+	}
+	latency, throttled := d.stats.reset()
+
+	current := int(d.workerCount.Load())
+	target, reason := scaleTarget(current, int(d.minWorkers.Load()), int(d.maxWorkers.Load()),
+		backlog, latency, throttled, d.cfg.Accrual.ScaleInterval)
+	if target == current {
+		return
+	}
+
+	direction := "up"
+	if target < current {
+		direction = "down"
+	}
+	d.metrics.AccrualScaling.WithLabelValues(direction, reason).Inc()
+	d.log.Infow("scaling accrual workers",
+		"from", current,
+		"to", target,
+		"reason", reason,
+		"backlog", backlog,
+		"latency", latency,
+		"throttled", throttled,
+	)
+
+	d.workerCount.Store(int64(target))
+	d.resize(ctx)
+}
+
+func scaleTarget(
+	current, minWorkers, maxWorkers int,
+	backlog int64,
+	latency time.Duration,
+	throttled int,
+	interval time.Duration,
+) (int, string) {
+	var target int
+	var reason string
+	switch {
+	case throttled > 0:
+		target, reason = current/2, scaleReasonRateLimited
+	case backlog == 0:
+		target, reason = minWorkers, scaleReasonIdle
+	case latency == 0:
+		// Nothing was sent yet, grow carefully until there is a latency to go by.
+		target, reason = current+1, scaleReasonBacklog
+	default:
+		needed := math.Ceil(float64(backlog) * latency.Seconds() / interval.Seconds())
+		target, reason = int(min(needed, float64(maxWorkers))), scaleReasonBacklog
+	}
+	return max(minWorkers, min(target, maxWorkers)), reason
+}
@@ -0,0 +1,86 @@
+package accrual
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScaleTarget(t *testing.T) {
+	const interval = 10 * time.Second
+
+	tests := []struct {
+		name       string
+		current    int
+		minWorkers int
+		maxWorkers int
+		backlog    int64
+		latency    time.Duration
+		throttled  int
+		want       int
+		wantReason string
+	}{
+		{name: "429 halves the pool", current: 8, minWorkers: 1, maxWorkers: 10, backlog: 1000,
+			latency: time.Second, throttled: 1, want: 4, wantReason: scaleReasonRateLimited},
+		{name: "429 keeps the minimum", current: 1, minWorkers: 1, maxWorkers: 10, backlog: 1000,
+			latency: time.Second, throttled: 3, want: 1, wantReason: scaleReasonRateLimited},
+		{name: "429 wins over an idle backlog", current: 6, minWorkers: 2, maxWorkers: 10,
+			throttled: 1, want: 3, wantReason: scaleReasonRateLimited},
+		{name: "idle shrinks to the minimum", current: 8, minWorkers: 2, maxWorkers: 10,
+			latency: time.Second, want: 2, wantReason: scaleReasonIdle},
+		{name: "no latency yet grows by one", current: 3, minWorkers: 1, maxWorkers: 10, backlog: 1000,
+			want: 4, wantReason: scaleReasonBacklog},
+		{name: "no latency yet stops at the maximum", current: 10, minWorkers: 1, maxWorkers: 10, backlog: 1000,
+			want: 10, wantReason: scaleReasonBacklog},
+		{name: "latency sets the size", current: 3, minWorkers: 1, maxWorkers: 10, backlog: 100,
+			latency: 500 * time.Millisecond, want: 5, wantReason: scaleReasonBacklog},
+		{name: "partial worker rounds up", current: 3, minWorkers: 1, maxWorkers: 10, backlog: 101,
+			latency: 500 * time.Millisecond, want: 6, wantReason: scaleReasonBacklog},
+		{name: "small backlog shrinks the pool", current: 8, minWorkers: 1, maxWorkers: 10, backlog: 10,
+			latency: time.Second, want: 1, wantReason: scaleReasonBacklog},
+		{name: "growth is capped at the maximum", current: 3, minWorkers: 1, maxWorkers: 10, backlog: 100000,
+			latency: time.Second, want: 10, wantReason: scaleReasonBacklog},
+		{name: "shrinking stops at the minimum", current: 8, minWorkers: 4, maxWorkers: 10, backlog: 1,
+			latency: 10 * time.Millisecond, want: 4, wantReason: scaleReasonBacklog},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := scaleTarget(tt.current, tt.minWorkers, tt.maxWorkers, tt.backlog, tt.latency,
+				tt.throttled, interval)
+			if got != tt.want || reason != tt.wantReason {
+				t.Errorf("scaleTarget() = %d, %s, want %d, %s", got, reason, tt.want, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestSetWorkerBounds(t *testing.T) {
+	tests := []struct {
+		name       string
+		current    int64
+		minWorkers int
+		maxWorkers int
+		want       int64
+		wantResize bool
+	}{
+		{name: "size within the new bounds stays", current: 5, minWorkers: 2, maxWorkers: 8, want: 5},
+		{name: "size above the maximum shrinks", current: 9, minWorkers: 2, maxWorkers: 8, want: 8, wantResize: true},
+		{name: "size below the minimum grows", current: 1, minWorkers: 2, maxWorkers: 8, want: 2, wantResize: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Dispatcher{resizeChan: make(chan struct{}, 1)}
+			d.workerCount.Store(tt.current)
+
+			d.SetWorkerBounds(tt.minWorkers, tt.maxWorkers)
+
+			if got := d.workerCount.Load(); got != tt.want {
+				t.Errorf("worker count = %d, want %d", got, tt.want)
+			}
+			if resize := len(d.resizeChan) == 1; resize != tt.wantResize {
+				t.Errorf("resize requested: %v, want %v", resize, tt.wantResize)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package accrual
+
+import "sync"
+
+// inFlight holds the numbers of orders queued or being processed, so that a sweep running while an
+// order is still in the queue does not queue it again.
+type inFlight struct {
+	numbers map[string]struct{}
+	mu      sync.Mutex
+}
+
+func newInFlight() *inFlight {
+	return &inFlight{numbers: make(map[string]struct{})}
+}
+
+// Add reports false if number is already in flight.
+func (f *inFlight) Add(number string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.numbers[number]; ok {
+		return false
+	}
+	f.numbers[number] = struct{}{}
+	return true
+}
+
+// Numbers returns a snapshot of the numbers in flight.
+func (f *inFlight) Numbers() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	numbers := make([]string, 0, len(f.numbers))
+	for number := range f.numbers {
+		numbers = append(numbers, number)
+	}
+	return numbers
+}
+
+func (f *inFlight) Done(number string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.numbers, number)
+}
@@ -0,0 +1,54 @@
+package accrual
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestInFlight(t *testing.T) {
+	tests := []struct {
+		name        string
+		add         []string
+		done        []string
+		wantAdded   []bool
+		wantNumbers []string
+	}{
+		{name: "distinct numbers", add: []string{"1", "2"}, wantAdded: []bool{true, true},
+			wantNumbers: []string{"1", "2"}},
+		{name: "duplicate is refused", add: []string{"1", "1"}, wantAdded: []bool{true, false},
+			wantNumbers: []string{"1"}},
+		{name: "done releases the number", add: []string{"1", "2"}, done: []string{"1"},
+			wantAdded: []bool{true, true}, wantNumbers: []string{"2"}},
+		{name: "done of an unknown number", add: []string{"1"}, done: []string{"3"},
+			wantAdded: []bool{true}, wantNumbers: []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newInFlight()
+			for i, number := range tt.add {
+				if added := f.Add(number); added != tt.wantAdded[i] {
+					t.Errorf("Add(%s) = %v, want %v", number, added, tt.wantAdded[i])
+				}
+			}
+			for _, number := range tt.done {
+				f.Done(number)
+			}
+
+			numbers := f.Numbers()
+			slices.Sort(numbers)
+			if !slices.Equal(numbers, tt.wantNumbers) {
+				t.Errorf("Numbers() = %v, want %v", numbers, tt.wantNumbers)
+			}
+		})
+	}
+}
+
+func TestInFlightReleasedNumberCanBeAddedAgain(t *testing.T) {
+	f := newInFlight()
+	f.Add("1")
+	f.Done("1")
+	if !f.Add("1") {
+		t.Error("Add refused a number released by Done")
+	}
+}
@@ -0,0 +1,20 @@
+package accrual
+
+import (
+	"context"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+// Mart is the part of mart.Mart used by the dispatcher, its workers, the results and the registrar.
+type Mart interface {
+	ListenNewOrders(ctx context.Context, wake chan<- struct{})
+	GetNewOrders(ctx context.Context, exclude []string) ([]models.OrderWithTime, error)
+	CountDueOrders(ctx context.Context) (int64, error)
+	UpdateOrdersAccrual(ctx context.Context, orders []models.Order) error
+
+	GetDueRegistrations(ctx context.Context) ([]models.Registration, error)
+	SetRegistration(ctx context.Context, number string, state string) error
+	DelayRegistration(ctx context.Context, number string, attempts int, nextAt time.Time) error
+}
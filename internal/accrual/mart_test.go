@@ -0,0 +1,93 @@
+package accrual
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+// fakeMart records the calls of the dispatcher and the results; calls it does not implement panic.
+type fakeMart struct {
+	Mart
+	mu sync.Mutex
+
+	newOrders []models.OrderWithTime
+	polled    bool
+	exclude   []string
+
+	// updateErrs are returned by successive UpdateOrdersAccrual calls, later calls succeed.
+	updateErrs []error
+	updates    [][]models.Order
+
+	registrations []models.Registration
+	// registrationStates holds the final registration state set for each order.
+	registrationStates map[string]string
+	delays             map[string]delay
+}
+
+// delay is a recorded DelayRegistration call.
+type delay struct {
+	attempts int
+	nextAt   time.Time
+}
+
+func (m *fakeMart) GetNewOrders(_ context.Context, exclude []string) ([]models.OrderWithTime, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.polled = true
+	m.exclude = exclude
+	return m.newOrders, nil
+}
+
+func (m *fakeMart) UpdateOrdersAccrual(_ context.Context, orders []models.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.updateErrs) > 0 {
+		err := m.updateErrs[0]
+		m.updateErrs = m.updateErrs[1:]
+		return err
+	}
+	m.updates = append(m.updates, orders)
+	return nil
+}
+
+// stored returns the stored results by order number and the number of batches they came in.
+func (m *fakeMart) stored() (map[string]models.Order, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := make(map[string]models.Order)
+	for _, batch := range m.updates {
+		for _, order := range batch {
+			stored[order.Order] = order
+		}
+	}
+	return stored, len(m.updates)
+}
+
+func (m *fakeMart) GetDueRegistrations(context.Context) ([]models.Registration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.registrations, nil
+}
+
+func (m *fakeMart) SetRegistration(_ context.Context, number string, state string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.registrationStates == nil {
+		m.registrationStates = make(map[string]string)
+	}
+	m.registrationStates[number] = state
+	return nil
+}
+
+func (m *fakeMart) DelayRegistration(_ context.Context, number string, attempts int, nextAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.delays == nil {
+		m.delays = make(map[string]delay)
+	}
+	m.delays[number] = delay{attempts: attempts, nextAt: nextAt}
+	return nil
+}
@@ -0,0 +1,131 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+	"github.com/tiunovvv/gophermart/internal/models"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// Registration retries back off exponentially from minRegisterBackoff up to maxRegisterBackoff.
+const (
+	minRegisterBackoff = time.Second
+	maxRegisterBackoff = 5 * time.Minute
+)
+
+// registrar registers orders uploaded with goods in the accrual system. A registered order is handed over
+// to polling by waking the dispatcher.
+type registrar struct {
+	mart        Mart
+	log         *zap.SugaredLogger
+	client      *accrualclient.Client
+	limiter     *rate.Limiter
+	maxAttempts int
+	interval    time.Duration
+	wakeChan    chan struct{}
+	// registered wakes the dispatcher.
+	registered chan<- struct{}
+	done       chan struct{}
+}
+
+// nudge makes the registrar look for due registrations now.
+func (r *registrar) nudge() {
+	select {
+	case r.wakeChan <- struct{}{}:
+	default:
+	}
+}
+
+func (r *registrar) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.registerDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wakeChan:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *registrar) registerDue(ctx context.Context) {
+	registrations, err := r.mart.GetDueRegistrations(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, registration := range registrations {
+		if err := r.limiter.Wait(ctx); err != nil {
+			return
+		}
+		if !r.register(ctx, registration) {
+			return
+		}
+	}
+}
+
+// register sends one registration and reports whether the rest of the round may go on.
+func (r *registrar) register(ctx context.Context, registration models.Registration) bool {
+	goods := make([]accrualclient.Good, 0, len(registration.Goods))
+	for _, good := range registration.Goods {
+		goods = append(goods, accrualclient.Good{Description: good.Description, Price: good.Price})
+	}
+	err := r.client.RegisterOrder(ctx, accrualclient.OrderRegistration{Order: registration.Number, Goods: goods})
+
+	var rateErr *accrualclient.RateLimitError
+	var openErr *accrualclient.CircuitOpenError
+	var statusErr *accrualclient.StatusError
+	switch {
+	case err == nil, errors.Is(err, accrualclient.ErrAlreadyRegistered):
+		if !r.setRegistration(ctx, registration.Number, models.RegistrationRegistered) {
+			return true
+		}
+		select {
+		case r.registered <- struct{}{}:
+		default:
+		}
+		return true
+	case errors.As(err, &rateErr), errors.As(err, &openErr), errors.Is(err, context.Canceled):
+		// Not the order's fault, the next round retries it without counting an attempt.
+		return false
+	case errors.As(err, &statusErr) && statusErr.Code < 500:
+		r.log.Errorw("accrual refused order registration", "order", registration.Number, "error", err)
+		r.setRegistration(ctx, registration.Number, models.RegistrationFailed)
+		return true
+	}
+
+	attempts := registration.Attempts + 1
+	if attempts >= r.maxAttempts {
+		r.log.Errorw("giving up order registration", "order", registration.Number, "attempts", attempts,
+			"error", err)
+		r.setRegistration(ctx, registration.Number, models.RegistrationFailed)
+		return true
+	}
+
+	backoff := min(minRegisterBackoff<<(attempts-1), maxRegisterBackoff)
+	r.log.Warnw("failed to register order, retrying", "order", registration.Number, "attempts", attempts,
+		"retry_in", backoff, "error", err)
+	if err := r.mart.DelayRegistration(ctx, registration.Number, attempts, time.Now().Add(backoff)); err != nil {
+		r.log.Errorw("failed to delay order registration", "order", registration.Number, "error", err)
+	}
+	return true
+}
+
+// setRegistration stores the registration state of an order and reports whether it succeeded.
+func (r *registrar) setRegistration(ctx context.Context, number string, state string) bool {
+	if err := r.mart.SetRegistration(ctx, number, state); err != nil {
+		r.log.Errorw("failed to store order registration state", "order", number, "state", state, "error", err)
+		return false
+	}
+	return true
+}
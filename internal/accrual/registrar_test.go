@@ -0,0 +1,168 @@
+package accrual
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+	"github.com/tiunovvv/gophermart/internal/accrualclient/accrualstub"
+	"github.com/tiunovvv/gophermart/internal/models"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+func newTestRegistrar(url string, mart Mart) (*registrar, chan struct{}) {
+	registered := make(chan struct{}, 1)
+	return &registrar{
+		mart:        mart,
+		log:         zap.NewNop().Sugar(),
+		client:      accrualclient.New(url, nil, nil),
+		limiter:     rate.NewLimiter(rate.Inf, 1),
+		maxAttempts: 3,
+		registered:  registered,
+	}, registered
+}
+
+func TestRegister(t *testing.T) {
+	const number = "12345678903"
+
+	tests := []struct {
+		name         string
+		registration models.Registration
+		setup        func(stub *accrualstub.Server)
+		wantGoOn     bool
+		wantState    string
+		wantAttempts int
+		wantBackoff  time.Duration
+	}{
+		{
+			name:         "accepted order is registered",
+			registration: models.Registration{Number: number},
+			wantGoOn:     true,
+			wantState:    models.RegistrationRegistered,
+		},
+		{
+			name:         "already registered order is registered",
+			registration: models.Registration{Number: number},
+			setup: func(stub *accrualstub.Server) {
+				stub.SetOrder(accrualclient.Order{Order: number, Status: accrualclient.StatusRegistered})
+			},
+			wantGoOn:  true,
+			wantState: models.RegistrationRegistered,
+		},
+		{
+			name:         "refused order fails",
+			registration: models.Registration{Number: ""},
+			wantGoOn:     true,
+			wantState:    models.RegistrationFailed,
+		},
+		{
+			name:         "rate limit stops the round without an attempt",
+			registration: models.Registration{Number: number},
+			setup: func(stub *accrualstub.Server) {
+				stub.RateLimit(1, time.Minute)
+			},
+		},
+		{
+			name:         "server error is retried after a backoff",
+			registration: models.Registration{Number: number},
+			setup: func(stub *accrualstub.Server) {
+				stub.Fail(1)
+			},
+			wantGoOn:     true,
+			wantAttempts: 1,
+			wantBackoff:  minRegisterBackoff,
+		},
+		{
+			name:         "backoff doubles with every attempt",
+			registration: models.Registration{Number: number, Attempts: 1},
+			setup: func(stub *accrualstub.Server) {
+				stub.Fail(1)
+			},
+			wantGoOn:     true,
+			wantAttempts: 2,
+			wantBackoff:  2 * minRegisterBackoff,
+		},
+		{
+			name:         "last attempt gives up",
+			registration: models.Registration{Number: number, Attempts: 2},
+			setup: func(stub *accrualstub.Server) {
+				stub.Fail(1)
+			},
+			wantGoOn:  true,
+			wantState: models.RegistrationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := accrualstub.NewServer()
+			defer stub.Close()
+			if tt.setup != nil {
+				tt.setup(stub)
+			}
+			mart := &fakeMart{}
+			r, registered := newTestRegistrar(stub.URL, mart)
+
+			start := time.Now()
+			if goOn := r.register(context.Background(), tt.registration); goOn != tt.wantGoOn {
+				t.Errorf("register() = %v, want %v", goOn, tt.wantGoOn)
+			}
+
+			if state := mart.registrationStates[tt.registration.Number]; state != tt.wantState {
+				t.Errorf("registration state = %q, want %q", state, tt.wantState)
+			}
+			if woken := len(registered) > 0; woken != (tt.wantState == models.RegistrationRegistered) {
+				t.Errorf("dispatcher woken = %v", woken)
+			}
+			delay, delayed := mart.delays[tt.registration.Number]
+			if delayed != (tt.wantAttempts > 0) {
+				t.Fatalf("registration delayed = %v, want %v", delayed, tt.wantAttempts > 0)
+			}
+			if !delayed {
+				return
+			}
+			if delay.attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", delay.attempts, tt.wantAttempts)
+			}
+			if backoff := delay.nextAt.Sub(start); backoff < tt.wantBackoff || backoff > tt.wantBackoff+time.Second {
+				t.Errorf("backoff = %v, want %v", backoff, tt.wantBackoff)
+			}
+		})
+	}
+}
+
+func TestRegisterBackoffIsCapped(t *testing.T) {
+	stub := accrualstub.NewServer()
+	defer stub.Close()
+	stub.Fail(1)
+	mart := &fakeMart{}
+	r, _ := newTestRegistrar(stub.URL, mart)
+	r.maxAttempts = 100
+
+	start := time.Now()
+	r.register(context.Background(), models.Registration{Number: "12345678903", Attempts: 20})
+
+	if backoff := mart.delays["12345678903"].nextAt.Sub(start); backoff < maxRegisterBackoff ||
+		backoff > maxRegisterBackoff+time.Second {
+		t.Errorf("backoff = %v, want %v", backoff, maxRegisterBackoff)
+	}
+}
+
+func TestRegisterDueStopsOnRateLimit(t *testing.T) {
+	stub := accrualstub.NewServer()
+	defer stub.Close()
+	stub.RateLimit(1, time.Minute)
+	mart := &fakeMart{registrations: []models.Registration{{Number: "12345678903"}, {Number: "4561261212345467"}}}
+	r, _ := newTestRegistrar(stub.URL, mart)
+
+	r.registerDue(context.Background())
+
+	if requests := stub.Requests(); requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+	if len(mart.registrationStates) != 0 || len(mart.delays) != 0 {
+		t.Errorf("registrations changed: states %v, delays %v", mart.registrationStates, mart.delays)
+	}
+}
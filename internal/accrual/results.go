@@ -0,0 +1,108 @@
+package accrual
+
+import (
+	"context"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+// The results left when the dispatcher stops are stored within finalFlushTimeout, a failed attempt is
+// retried every finalFlushRetry.
+const (
+	finalFlushTimeout = 5 * time.Second
+	finalFlushRetry   = 100 * time.Millisecond
+)
+
+// results collects accrual results from all workers and stores them in batches, when size results are
+// pending or every interval. Orders stay in flight until their result is stored, so they are not
+// polled again in between.
+type results struct {
+	mart     Mart
+	log      *zap.SugaredLogger
+	inFlight *inFlight
+	// input is closed by the dispatcher once every worker has stopped.
+	input    chan models.Order
+	done     chan struct{}
+	pending  map[string]models.Order
+	size     int
+	interval time.Duration
+	// stopTimeout bounds flushOnStop.
+	stopTimeout time.Duration
+}
+
+func newResults(mart Mart, log *zap.SugaredLogger, inFlight *inFlight, size int, interval time.Duration) *results {
+	return &results{
+		mart:        mart,
+		log:         log,
+		inFlight:    inFlight,
+		input:       make(chan models.Order, size),
+		done:        make(chan struct{}),
+		pending:     make(map[string]models.Order, size),
+		size:        size,
+		interval:    interval,
+		stopTimeout: finalFlushTimeout,
+	}
+}
+
+// run stores results until input is closed and then flushes the rest with a fresh context.
+func (r *results) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case order, ok := <-r.input:
+			if !ok {
+				r.flushOnStop(ctx)
+				return
+			}
+			// A later result of the same order supersedes the earlier one.
+			r.pending[order.Order] = order
+			if len(r.pending) >= r.size {
+				r.flush(ctx)
+			}
+		case <-ticker.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+// flush stores the pending results. On failure they are kept for the next flush.
+func (r *results) flush(ctx context.Context) bool {
+	if len(r.pending) == 0 {
+		return true
+	}
+
+	orders := make([]models.Order, 0, len(r.pending))
+	for _, order := range r.pending {
+		orders = append(orders, order)
+	}
+	if err := r.mart.UpdateOrdersAccrual(ctx, orders); err != nil {
+		return false
+	}
+
+	for number := range r.pending {
+		r.inFlight.Done(number)
+		delete(r.pending, number)
+	}
+	return true
+}
+
+func (r *results) flushOnStop(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.stopTimeout)
+	defer cancel()
+
+	for !r.flush(ctx) {
+		select {
+		case <-ctx.Done():
+			r.log.Errorw("failed to store accrual results on shutdown, the orders will be polled again",
+				"orders", len(r.pending))
+			return
+		case <-time.After(finalFlushRetry):
+		}
+	}
+}
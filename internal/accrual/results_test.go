@@ -0,0 +1,124 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+func TestResults(t *testing.T) {
+	errDB := errors.New("connection reset")
+	processed := func(number string, accrual float64) models.Order {
+		return models.Order{Order: number, Status: models.OrderProcessed, Accrual: accrual}
+	}
+
+	tests := []struct {
+		name       string
+		size       int
+		updateErrs []error
+		input      []models.Order
+		// flushed is the number of batches stored before input is closed.
+		flushed      int
+		want         map[string]models.Order
+		wantInFlight int
+	}{
+		{
+			name:  "results left on stop are stored",
+			size:  100,
+			input: []models.Order{processed("1", 10), processed("2", 20), processed("3", 30)},
+			want:  map[string]models.Order{"1": processed("1", 10), "2": processed("2", 20), "3": processed("3", 30)},
+		},
+		{
+			name:    "full batch is stored at once",
+			size:    2,
+			input:   []models.Order{processed("1", 10), processed("2", 20), processed("3", 30)},
+			flushed: 1,
+			want:    map[string]models.Order{"1": processed("1", 10), "2": processed("2", 20), "3": processed("3", 30)},
+		},
+		{
+			name:       "failed batch is retried with the next one",
+			size:       1,
+			updateErrs: []error{errDB},
+			input:      []models.Order{processed("1", 10), processed("2", 20)},
+			flushed:    1,
+			want:       map[string]models.Order{"1": processed("1", 10), "2": processed("2", 20)},
+		},
+		{
+			name:       "failed batch is retried on stop",
+			size:       100,
+			updateErrs: []error{errDB},
+			input:      []models.Order{processed("1", 10)},
+			want:       map[string]models.Order{"1": processed("1", 10)},
+		},
+		{
+			name: "later result of an order supersedes the earlier",
+			size: 100,
+			input: []models.Order{
+				{Order: "1", Status: models.OrderProcessing}, processed("1", 10),
+			},
+			want: map[string]models.Order{"1": processed("1", 10)},
+		},
+		{
+			name:         "orders stay in flight when the store keeps failing",
+			size:         100,
+			updateErrs:   []error{errDB, errDB, errDB, errDB, errDB, errDB, errDB, errDB},
+			input:        []models.Order{processed("1", 10), processed("2", 20)},
+			want:         map[string]models.Order{},
+			wantInFlight: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mart := &fakeMart{updateErrs: tt.updateErrs}
+			inFlight := newInFlight()
+			r := newResults(mart, zap.NewNop().Sugar(), inFlight, tt.size, time.Hour)
+			r.stopTimeout = 3 * finalFlushRetry
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go r.run(ctx)
+			for _, order := range tt.input {
+				inFlight.Add(order.Order)
+				r.input <- order
+			}
+			waitFor(t, func() bool {
+				_, batches := mart.stored()
+				return batches >= tt.flushed
+			})
+
+			// The dispatcher cancels the context before it closes input.
+			cancel()
+			close(r.input)
+			<-r.done
+
+			stored, _ := mart.stored()
+			if len(stored) != len(tt.want) {
+				t.Fatalf("stored %v, want %v", stored, tt.want)
+			}
+			for number, want := range tt.want {
+				if stored[number] != want {
+					t.Errorf("stored %s = %+v, want %+v", number, stored[number], want)
+				}
+			}
+			if got := len(inFlight.Numbers()); got != tt.wantInFlight {
+				t.Errorf("%d orders in flight, want %d", got, tt.wantInFlight)
+			}
+		})
+	}
+}
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
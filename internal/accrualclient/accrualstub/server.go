@@ -0,0 +1,175 @@
+// Package accrualstub is an in-process accrual system for tests. It serves GET /api/orders/{number}
+// from a configurable set of orders, registers orders on POST /api/orders and can simulate rate limits,
+// server errors, latency and malformed bodies.
+package accrualstub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+)
+
+type Server struct {
+	*httptest.Server
+
+	orders     map[string]accrualclient.Order
+	goods      map[string][]accrualclient.Good
+	malformed  map[string]bool
+	mu         sync.Mutex
+	latency    time.Duration
+	retryAfter time.Duration
+	// limited is the number of next requests answered with 429.
+	limited int
+	// failing is the number of next requests answered with 500.
+	failing  int
+	requests int
+}
+
+// NewServer starts a stub that does not know any order. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		orders:    make(map[string]accrualclient.Order),
+		goods:     make(map[string][]accrualclient.Good),
+		malformed: make(map[string]bool),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// SetOrder makes the stub answer with order for its number.
+func (s *Server) SetOrder(order accrualclient.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[order.Order] = order
+}
+
+// SetMalformed makes the stub answer 200 with an invalid JSON body for number.
+func (s *Server) SetMalformed(number string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.malformed[number] = true
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// RateLimit answers the next n requests with 429 and the given Retry-After.
+func (s *Server) RateLimit(n int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limited = n
+	s.retryAfter = retryAfter
+}
+
+// Fail answers the next n requests with 500.
+func (s *Server) Fail(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = n
+}
+
+// Goods returns the goods an order was registered with and whether it was registered.
+func (s *Server) Goods(number string) ([]accrualclient.Good, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	goods, ok := s.goods[number]
+	return goods, ok
+}
+
+// Requests returns the number of requests served so far.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	number, isGet := strings.CutPrefix(r.URL.Path, "/api/orders/")
+	isGet = isGet && r.Method == http.MethodGet
+	isPost := r.URL.Path == "/api/orders" && r.Method == http.MethodPost
+	if !isGet && !isPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests++
+	latency := s.latency
+	limited := s.limited > 0
+	if limited {
+		s.limited--
+	}
+	failing := !limited && s.failing > 0
+	if failing {
+		s.failing--
+	}
+	retryAfter := s.retryAfter
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(latency):
+		}
+	}
+
+	switch {
+	case limited:
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		http.Error(w, "No more than N requests per minute allowed", http.StatusTooManyRequests)
+	case failing:
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	case isPost:
+		s.register(w, r)
+	default:
+		s.getOrder(w, number)
+	}
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, number string) {
+	s.mu.Lock()
+	order, known := s.orders[number]
+	malformed := s.malformed[number]
+	s.mu.Unlock()
+
+	switch {
+	case malformed:
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"order":`))
+	case !known:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(order)
+	}
+}
+
+// register accepts an order as REGISTERED; an order the stub already knows gets 409.
+func (s *Server) register(w http.ResponseWriter, r *http.Request) {
+	var registration accrualclient.OrderRegistration
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil || registration.Order == "" {
+		http.Error(w, "invalid registration", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, known := s.orders[registration.Order]; known {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	s.orders[registration.Order] = accrualclient.Order{Order: registration.Order, Status: accrualclient.StatusRegistered}
+	s.goods[registration.Order] = registration.Goods
+	w.WriteHeader(http.StatusAccepted)
+}
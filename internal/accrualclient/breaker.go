@@ -0,0 +1,147 @@
+package accrualclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	// BreakerClosed lets every request through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects requests until the cool-down passes.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single probe through, its result closes or reopens the breaker.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// Breaker stops calls to the accrual system after Threshold consecutive failures.
+// Only transport errors and 5xx responses count as failures. Breaker is safe for concurrent use.
+type Breaker struct {
+	// OnStateChange, if set, is called on every transition. It must not call back into the breaker.
+	OnStateChange func(from, to BreakerState)
+
+	now       func() time.Time
+	mu        sync.Mutex
+	state     BreakerState
+	openedAt  time.Time
+	threshold int
+	coolDown  time.Duration
+	failures  int
+	probing   bool
+	// generation changes on every transition. Outcomes of requests let through in an earlier
+	// generation are ignored, so that a slow request cannot close a breaker that opened after it started.
+	generation uint64
+}
+
+// NewBreaker returns a closed breaker. A threshold of 0 disables it.
+func NewBreaker(threshold int, coolDown time.Duration) *Breaker {
+	return &Breaker{
+		now:       time.Now,
+		state:     BreakerClosed,
+		threshold: threshold,
+		coolDown:  coolDown,
+	}
+}
+
+// State returns the current state, an open breaker whose cool-down has passed is reported as half-open.
+func (b *Breaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.coolDown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether a request may be sent now, otherwise it returns a *CircuitOpenError. The returned
+// generation must be passed to record with the request's outcome.
+func (b *Breaker) allow() (uint64, error) {
+	if b == nil || b.threshold == 0 {
+		return 0, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		wait := b.coolDown - b.now().Sub(b.openedAt)
+		if wait > 0 {
+			return 0, &CircuitOpenError{RetryAfter: wait}
+		}
+		b.setState(BreakerHalfOpen)
+	case BreakerHalfOpen:
+		if b.probing {
+			return 0, &CircuitOpenError{RetryAfter: defaultRetryAfter}
+		}
+	}
+	if b.state == BreakerHalfOpen {
+		b.probing = true
+	}
+	return b.generation, nil
+}
+
+// record accounts the result of a request that allow let through in generation.
+func (b *Breaker) record(generation uint64, err error) {
+	if b == nil || b.threshold == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+	b.probing = false
+	// A canceled request says nothing about the accrual system, the next one probes again.
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if !isFailure(err) {
+		b.failures = 0
+		b.setState(BreakerClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.setState(BreakerOpen)
+	}
+}
+
+func (b *Breaker) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	b.generation++
+	if b.OnStateChange != nil {
+		b.OnStateChange(from, state)
+	}
+}
+
+// isFailure tells whether err means the accrual system is unavailable rather than answering
+// about the order: 204, 409, 429, 4xx and malformed bodies prove that it is up.
+func isFailure(err error) bool {
+	var rateErr *RateLimitError
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrNotRegistered), errors.Is(err, ErrAlreadyRegistered), errors.Is(err, ErrMalformedResponse),
+		errors.As(err, &rateErr):
+		return false
+	case errors.As(err, &statusErr):
+		return statusErr.Code >= 500
+	default:
+		return true
+	}
+}
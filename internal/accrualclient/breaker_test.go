@@ -0,0 +1,140 @@
+package accrualclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	errDown := errors.New("connection refused")
+	const coolDown = time.Minute
+
+	// step either lets request req through (allow), records its outcome (record) or moves the clock.
+	type step struct {
+		allow     string
+		record    string
+		err       error
+		advance   time.Duration
+		wantAllow bool
+		want      BreakerState
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "consecutive failures open the breaker",
+			steps: []step{
+				{allow: "a", wantAllow: true, want: BreakerClosed},
+				{record: "a", err: errDown, want: BreakerClosed},
+				{allow: "b", wantAllow: true, want: BreakerClosed},
+				{record: "b", err: errDown, want: BreakerOpen},
+				{allow: "c", want: BreakerOpen},
+			},
+		},
+		{
+			name: "success started before opening is ignored",
+			steps: []step{
+				{allow: "slow", wantAllow: true, want: BreakerClosed},
+				{allow: "a", wantAllow: true, want: BreakerClosed},
+				{record: "a", err: errDown, want: BreakerClosed},
+				{allow: "b", wantAllow: true, want: BreakerClosed},
+				{record: "b", err: errDown, want: BreakerOpen},
+				{record: "slow", want: BreakerOpen},
+			},
+		},
+		{
+			name: "failure started before closing is ignored",
+			steps: []step{
+				{allow: "a", wantAllow: true},
+				{record: "a", err: errDown},
+				{allow: "b", wantAllow: true},
+				{allow: "slow", wantAllow: true},
+				{record: "b", err: errDown, want: BreakerOpen},
+				{advance: coolDown, want: BreakerHalfOpen},
+				{allow: "probe", wantAllow: true, want: BreakerHalfOpen},
+				{record: "probe", want: BreakerClosed},
+				{record: "slow", err: errDown, want: BreakerClosed},
+			},
+		},
+		{
+			name: "only one probe while half-open",
+			steps: []step{
+				{allow: "a", wantAllow: true},
+				{record: "a", err: errDown},
+				{allow: "b", wantAllow: true},
+				{record: "b", err: errDown, want: BreakerOpen},
+				{advance: coolDown, want: BreakerHalfOpen},
+				{allow: "probe", wantAllow: true, want: BreakerHalfOpen},
+				{allow: "c", want: BreakerHalfOpen},
+				{record: "probe", err: errDown, want: BreakerOpen},
+			},
+		},
+		{
+			name: "canceled request keeps the failure count",
+			steps: []step{
+				{allow: "a", wantAllow: true},
+				{record: "a", err: errDown},
+				{allow: "b", wantAllow: true},
+				{record: "b", err: context.Canceled, want: BreakerClosed},
+				{allow: "c", wantAllow: true},
+				{record: "c", err: errDown, want: BreakerOpen},
+			},
+		},
+		{
+			name: "canceled probe leaves the breaker half-open",
+			steps: []step{
+				{allow: "a", wantAllow: true},
+				{record: "a", err: errDown},
+				{allow: "b", wantAllow: true},
+				{record: "b", err: errDown, want: BreakerOpen},
+				{advance: coolDown, want: BreakerHalfOpen},
+				{allow: "probe", wantAllow: true, want: BreakerHalfOpen},
+				{record: "probe", err: fmt.Errorf("failed to get order: %w", context.Canceled), want: BreakerHalfOpen},
+				{allow: "next probe", wantAllow: true, want: BreakerHalfOpen},
+			},
+		},
+		{
+			name: "answers about the order are not failures",
+			steps: []step{
+				{allow: "a", wantAllow: true},
+				{record: "a", err: ErrNotRegistered, want: BreakerClosed},
+				{allow: "b", wantAllow: true},
+				{record: "b", err: &StatusError{Code: 404}, want: BreakerClosed},
+				{allow: "c", wantAllow: true},
+				{record: "c", err: &RateLimitError{RetryAfter: time.Second}, want: BreakerClosed},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+			b := NewBreaker(2, coolDown)
+			b.now = func() time.Time { return now }
+			generations := make(map[string]uint64)
+
+			for i, s := range tt.steps {
+				switch {
+				case s.allow != "":
+					generation, err := b.allow()
+					if allowed := err == nil; allowed != s.wantAllow {
+						t.Fatalf("step %d: allow(%s) allowed = %v, want %v", i, s.allow, allowed, s.wantAllow)
+					}
+					generations[s.allow] = generation
+				case s.record != "":
+					b.record(generations[s.record], s.err)
+				default:
+					now = now.Add(s.advance)
+				}
+				if s.want != "" && b.State() != s.want {
+					t.Fatalf("step %d: state = %s, want %s", i, b.State(), s.want)
+				}
+			}
+		})
+	}
+}
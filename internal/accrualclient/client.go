@@ -0,0 +1,219 @@
+// Package accrualclient talks to the accrual system over its HTTP API.
+package accrualclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusRegistered Status = "REGISTERED"
+	StatusInvalid    Status = "INVALID"
+	StatusProcessing Status = "PROCESSING"
+	StatusProcessed  Status = "PROCESSED"
+)
+
+// Order is the accrual system's view of an order. Accrual is set for PROCESSED orders only.
+type Order struct {
+	Order   string  `json:"order"`
+	Status  Status  `json:"status"`
+	Accrual float64 `json:"accrual,omitempty"`
+}
+
+type Good struct {
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+}
+
+// OrderRegistration is the body of POST /api/orders.
+type OrderRegistration struct {
+	Order string `json:"order"`
+	Goods []Good `json:"goods"`
+}
+
+const (
+	// maxBodySize caps how much of a response is read, an order is far smaller.
+	maxBodySize = 1 << 20
+	// defaultRetryAfter is used when a 429 response has no usable Retry-After header.
+	defaultRetryAfter = time.Second
+)
+
+// Client is safe for concurrent use.
+type Client struct {
+	httpClient *http.Client
+	breaker    *Breaker
+	baseURL    string
+}
+
+// New returns a client for the accrual system at baseURL. A nil httpClient is replaced
+// with one that times out after 5 seconds, a nil breaker never opens.
+func New(baseURL string, httpClient *http.Client, breaker *Breaker) *Client {
+	const defaultTimeout = 5 * time.Second
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+	return &Client{
+		httpClient: httpClient,
+		breaker:    breaker,
+		baseURL:    baseURL,
+	}
+}
+
+// BreakerState returns the state of the client's circuit breaker.
+func (c *Client) BreakerState() BreakerState {
+	return c.breaker.State()
+}
+
+// GetOrder asks for the accrual of an order. Besides transport errors it returns ErrNotRegistered,
+// *RateLimitError, *CircuitOpenError, *StatusError and errors wrapping ErrMalformedResponse.
+func (c *Client) GetOrder(ctx context.Context, number string) (Order, error) {
+	generation, err := c.breaker.allow()
+	if err != nil {
+		return Order{}, err
+	}
+	order, err := c.getOrder(ctx, number)
+	c.breaker.record(generation, err)
+	return order, err
+}
+
+func (c *Client) getOrder(ctx context.Context, number string) (Order, error) {
+	var order Order
+	address, err := url.JoinPath(c.baseURL, "/api/orders/", number)
+	if err != nil {
+		return order, fmt.Errorf("failed to join path: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, http.NoBody)
+	if err != nil {
+		return order, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return order, fmt.Errorf("failed to get order from accrual: %w", err)
+	}
+	defer func() {
+		// Drain the rest so that the connection can be reused.
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodySize))
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return order, ErrNotRegistered
+	case http.StatusTooManyRequests:
+		return order, &RateLimitError{RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	default:
+		return order, &StatusError{Code: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBodySize)).Decode(&order); err != nil {
+		return order, fmt.Errorf("%w: %w", ErrMalformedResponse, err)
+	}
+	switch order.Status {
+	case StatusRegistered, StatusInvalid, StatusProcessing, StatusProcessed:
+	default:
+		return order, fmt.Errorf("%w: unknown status %q", ErrMalformedResponse, order.Status)
+	}
+	if order.Order != number {
+		return order, fmt.Errorf("%w: asked for order %s, got %s", ErrMalformedResponse, number, order.Order)
+	}
+	return order, nil
+}
+
+// RegisterOrder registers an order with its goods for the accrual calculation. Besides transport errors
+// it returns ErrAlreadyRegistered, *RateLimitError, *CircuitOpenError and *StatusError.
+func (c *Client) RegisterOrder(ctx context.Context, registration OrderRegistration) error {
+	generation, err := c.breaker.allow()
+	if err != nil {
+		return err
+	}
+	err = c.registerOrder(ctx, registration)
+	c.breaker.record(generation, err)
+	return err
+}
+
+func (c *Client) registerOrder(ctx context.Context, registration OrderRegistration) error {
+	address, err := url.JoinPath(c.baseURL, "/api/orders")
+	if err != nil {
+		return fmt.Errorf("failed to join path: %w", err)
+	}
+
+	body, err := json.Marshal(registration)
+	if err != nil {
+		return fmt.Errorf("failed to marshal registration: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to register order in accrual: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodySize))
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted, http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return ErrAlreadyRegistered
+	case http.StatusTooManyRequests:
+		return &RateLimitError{RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	default:
+		return &StatusError{Code: resp.StatusCode}
+	}
+}
+
+// retryAfter parses Retry-After given in seconds or as an HTTP date.
+func retryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return defaultRetryAfter
+}
+
+// Outcome is a short label of a GetOrder result for metrics: the HTTP status code,
+// "circuit_open", "malformed" or "error".
+func Outcome(err error) string {
+	var rateErr *RateLimitError
+	var openErr *CircuitOpenError
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+		return strconv.Itoa(http.StatusOK)
+	case errors.Is(err, ErrNotRegistered):
+		return strconv.Itoa(http.StatusNoContent)
+	case errors.As(err, &rateErr):
+		return strconv.Itoa(http.StatusTooManyRequests)
+	case errors.As(err, &openErr):
+		return "circuit_open"
+	case errors.As(err, &statusErr):
+		return strconv.Itoa(statusErr.Code)
+	case errors.Is(err, ErrMalformedResponse):
+		return "malformed"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,157 @@
+package accrualclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+	"github.com/tiunovvv/gophermart/internal/accrualclient/accrualstub"
+)
+
+const number = "12345678903"
+
+func TestClientGetOrder(t *testing.T) {
+	processed := accrualclient.Order{Order: number, Status: accrualclient.StatusProcessed, Accrual: 729.98}
+
+	tests := []struct {
+		name        string
+		setup       func(s *accrualstub.Server)
+		timeout     time.Duration
+		want        accrualclient.Order
+		wantErr     error
+		wantRetry   time.Duration
+		wantOutcome string
+	}{
+		{
+			name:        "processed order",
+			setup:       func(s *accrualstub.Server) { s.SetOrder(processed) },
+			want:        processed,
+			wantOutcome: "200",
+		},
+		{
+			name:        "unknown order",
+			wantErr:     accrualclient.ErrNotRegistered,
+			wantOutcome: "204",
+		},
+		{
+			name:        "rate limited",
+			setup:       func(s *accrualstub.Server) { s.RateLimit(1, 7*time.Second) },
+			wantRetry:   7 * time.Second,
+			wantOutcome: "429",
+		},
+		{
+			name:        "malformed body",
+			setup:       func(s *accrualstub.Server) { s.SetMalformed(number) },
+			wantErr:     accrualclient.ErrMalformedResponse,
+			wantOutcome: "malformed",
+		},
+		{
+			name: "slow response",
+			setup: func(s *accrualstub.Server) {
+				s.SetOrder(processed)
+				s.SetLatency(time.Second)
+			},
+			timeout:     50 * time.Millisecond,
+			wantOutcome: "error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := accrualstub.NewServer()
+			defer stub.Close()
+			if tt.setup != nil {
+				tt.setup(stub)
+			}
+			var httpClient *http.Client
+			if tt.timeout > 0 {
+				httpClient = &http.Client{Timeout: tt.timeout}
+			}
+			client := accrualclient.New(stub.URL, httpClient, nil)
+
+			order, err := client.GetOrder(context.Background(), number)
+			if outcome := accrualclient.Outcome(err); outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %s, want %s (error %v)", outcome, tt.wantOutcome, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			var rateErr *accrualclient.RateLimitError
+			if errors.As(err, &rateErr) && rateErr.RetryAfter != tt.wantRetry {
+				t.Errorf("RetryAfter = %s, want %s", rateErr.RetryAfter, tt.wantRetry)
+			}
+			if err == nil && order != tt.want {
+				t.Errorf("order = %+v, want %+v", order, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientRegisterOrder(t *testing.T) {
+	registration := accrualclient.OrderRegistration{
+		Order: number,
+		Goods: []accrualclient.Good{{Description: "Чайник Bork", Price: 7000}},
+	}
+
+	tests := []struct {
+		name       string
+		setup      func(s *accrualstub.Server)
+		wantErr    error
+		wantRetry  time.Duration
+		wantStored bool
+	}{
+		{name: "new order", wantStored: true},
+		{
+			name: "already registered",
+			setup: func(s *accrualstub.Server) {
+				s.SetOrder(accrualclient.Order{Order: number, Status: accrualclient.StatusProcessing})
+			},
+			wantErr: accrualclient.ErrAlreadyRegistered,
+		},
+		{
+			name:      "rate limited",
+			setup:     func(s *accrualstub.Server) { s.RateLimit(1, 3*time.Second) },
+			wantRetry: 3 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := accrualstub.NewServer()
+			defer stub.Close()
+			if tt.setup != nil {
+				tt.setup(stub)
+			}
+			client := accrualclient.New(stub.URL, nil, nil)
+
+			err := client.RegisterOrder(context.Background(), registration)
+			var rateErr *accrualclient.RateLimitError
+			switch {
+			case tt.wantRetry > 0:
+				if !errors.As(err, &rateErr) || rateErr.RetryAfter != tt.wantRetry {
+					t.Fatalf("error = %v, want a rate limit for %s", err, tt.wantRetry)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			goods, stored := stub.Goods(number)
+			if stored != tt.wantStored {
+				t.Fatalf("stub stored the registration: %v, want %v", stored, tt.wantStored)
+			}
+			if !tt.wantStored {
+				return
+			}
+			if len(goods) != len(registration.Goods) || goods[0] != registration.Goods[0] {
+				t.Errorf("stub stored goods %v, want %v", goods, registration.Goods)
+			}
+			order, err := client.GetOrder(context.Background(), number)
+			if err != nil || order.Status != accrualclient.StatusRegistered {
+				t.Errorf("GetOrder after registration = %+v, %v, want REGISTERED", order, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package accrualclient
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNotRegistered means the accrual system does not know the order (204).
+	ErrNotRegistered = errors.New("order is not registered in accrual")
+	// ErrAlreadyRegistered means the order was registered before (409).
+	ErrAlreadyRegistered = errors.New("order is already registered in accrual")
+	// ErrMalformedResponse means a 200 response that is not a valid order.
+	ErrMalformedResponse = errors.New("malformed accrual response")
+)
+
+// RateLimitError is returned on 429, requests should pause for RetryAfter.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("accrual rate limit exceeded, retry after %s", e.RetryAfter)
+}
+
+// StatusError is returned for any other unexpected status code.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected accrual response status %d", e.Code)
+}
+
+// CircuitOpenError is returned without calling the accrual system while the breaker is open,
+// requests should pause for RetryAfter.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("accrual circuit breaker is open, retry after %s", e.RetryAfter)
+}
@@ -0,0 +1,18 @@
+package accrualclient
+
+import "github.com/tiunovvv/gophermart/internal/models"
+
+// OrderStatus maps an accrual status to the gophermart one. REGISTERED is not a gophermart status:
+// for the user the order is already being processed.
+func (s Status) OrderStatus() (models.OrderStatus, bool) {
+	switch s {
+	case StatusRegistered, StatusProcessing:
+		return models.OrderProcessing, true
+	case StatusInvalid:
+		return models.OrderInvalid, true
+	case StatusProcessed:
+		return models.OrderProcessed, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,31 @@
+package accrualclient
+
+import (
+	"testing"
+
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+func TestStatusOrderStatus(t *testing.T) {
+	tests := []struct {
+		status Status
+		want   models.OrderStatus
+		wantOK bool
+	}{
+		{status: StatusRegistered, want: models.OrderProcessing, wantOK: true},
+		{status: StatusProcessing, want: models.OrderProcessing, wantOK: true},
+		{status: StatusInvalid, want: models.OrderInvalid, wantOK: true},
+		{status: StatusProcessed, want: models.OrderProcessed, wantOK: true},
+		{status: "NEW"},
+		{status: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			got, ok := tt.status.OrderStatus()
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("OrderStatus() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package accrualsystem
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+)
+
+type memoryOrder struct {
+	accrualclient.Order
+	goods []accrualclient.Good
+}
+
+// MemoryStorage keeps everything in memory, it is lost on restart.
+type MemoryStorage struct {
+	rules  []Rule
+	orders map[string]*memoryOrder
+	// numbers keeps registration order.
+	numbers []string
+	mu      sync.Mutex
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{orders: make(map[string]*memoryOrder)}
+}
+
+func (s *MemoryStorage) SaveRule(_ context.Context, rule Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.rules {
+		if r.Match == rule.Match {
+			return ErrRuleExists
+		}
+	}
+	s.rules = append(s.rules, rule)
+	return nil
+}
+
+func (s *MemoryStorage) Rules(context.Context) ([]Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Rule(nil), s.rules...), nil
+}
+
+func (s *MemoryStorage) RegisterOrder(_ context.Context, registration accrualclient.OrderRegistration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orders[registration.Order]; ok {
+		return ErrOrderExists
+	}
+	s.orders[registration.Order] = &memoryOrder{
+		Order: accrualclient.Order{Order: registration.Order, Status: accrualclient.StatusRegistered},
+		goods: append([]accrualclient.Good(nil), registration.Goods...),
+	}
+	s.numbers = append(s.numbers, registration.Order)
+	return nil
+}
+
+func (s *MemoryStorage) GetOrder(_ context.Context, number string) (accrualclient.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[number]
+	if !ok {
+		return accrualclient.Order{}, ErrOrderNotFound
+	}
+	return order.Order, nil
+}
+
+func (s *MemoryStorage) OrdersByStatus(
+	_ context.Context,
+	status accrualclient.Status,
+	limit int,
+) ([]accrualclient.OrderRegistration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var orders []accrualclient.OrderRegistration
+	for _, number := range s.numbers {
+		if len(orders) == limit {
+			break
+		}
+		if order := s.orders[number]; order.Status == status {
+			orders = append(orders, accrualclient.OrderRegistration{Order: number, Goods: order.goods})
+		}
+	}
+	return orders, nil
+}
+
+func (s *MemoryStorage) SetStatus(_ context.Context, number string, status accrualclient.Status, accrual float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[number]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	order.Status, order.Accrual = status, accrual
+	return nil
+}
+
+func (s *MemoryStorage) Close() {}
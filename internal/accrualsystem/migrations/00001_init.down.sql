@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS accrual_orders;
+DROP TABLE IF EXISTS accrual_rewards;
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS accrual_rewards(
+    id SERIAL PRIMARY KEY,
+    match VARCHAR(200) NOT NULL UNIQUE,
+    reward NUMERIC(12, 2) NOT NULL,
+    reward_type VARCHAR(2) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS accrual_orders(
+    id SERIAL PRIMARY KEY,
+    number VARCHAR(200) NOT NULL UNIQUE,
+    status VARCHAR(20) NOT NULL,
+    accrual NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    goods JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS accrual_orders_status_idx ON accrual_orders (status, id)
+    WHERE status IN ('REGISTERED', 'PROCESSING');
+
+COMMIT;
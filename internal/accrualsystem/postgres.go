@@ -0,0 +1,170 @@
+package accrualsystem
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+)
+
+// migrationsTable keeps the versions apart from gophermart's migrations when both share a database.
+const migrationsTable = "accrual_schema_migrations"
+
+//go:embed migrations/*.sql
+var migrationsDir embed.FS
+
+type PostgresStorage struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresStorage connects to databaseURI, a postgres:// URL, and applies the migrations.
+func NewPostgresStorage(ctx context.Context, databaseURI string) (*PostgresStorage, error) {
+	pool, err := pgxpool.New(ctx, databaseURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize a connection pool: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping DB: %w", err)
+	}
+	if err := runMigrations(databaseURI); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to run DB migrations: %w", err)
+	}
+	return &PostgresStorage{pool: pool}, nil
+}
+
+func runMigrations(databaseURI string) error {
+	u, err := url.Parse(databaseURI)
+	if err != nil {
+		return fmt.Errorf("failed to parse the DSN: %w", err)
+	}
+	query := u.Query()
+	query.Set("x-migrations-table", migrationsTable)
+	u.RawQuery = query.Encode()
+
+	d, err := iofs.New(migrationsDir, "migrations")
+	if err != nil {
+		return fmt.Errorf("failed to return an iofs driver: %w", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", d, u.String())
+	if err != nil {
+		return fmt.Errorf("failed to get a new migrate instance: %w", err)
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply migrations to the DB: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SaveRule(ctx context.Context, rule Rule) error {
+	const insertRule = `INSERT INTO accrual_rewards (match, reward, reward_type) VALUES ($1, $2, $3);`
+	if _, err := s.pool.Exec(ctx, insertRule, rule.Match, rule.Reward, rule.RewardType); err != nil {
+		if isUniqueViolation(err) {
+			return ErrRuleExists
+		}
+		return fmt.Errorf("failed to insert rule: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) Rules(ctx context.Context) ([]Rule, error) {
+	const selectRules = `SELECT match, reward, reward_type FROM accrual_rewards ORDER BY id;`
+	rows, err := s.pool.Query(ctx, selectRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select rules: %w", err)
+	}
+	rules, err := pgx.CollectRows(rows, pgx.RowToStructByPos[Rule])
+	if err != nil {
+		return nil, fmt.Errorf("failed to select rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (s *PostgresStorage) RegisterOrder(ctx context.Context, registration accrualclient.OrderRegistration) error {
+	goods, err := json.Marshal(registration.Goods)
+	if err != nil {
+		return fmt.Errorf("failed to marshal goods: %w", err)
+	}
+	const insertOrder = `INSERT INTO accrual_orders (number, status, goods) VALUES ($1, $2, $3);`
+	if _, err := s.pool.Exec(ctx, insertOrder, registration.Order, accrualclient.StatusRegistered, goods); err != nil {
+		if isUniqueViolation(err) {
+			return ErrOrderExists
+		}
+		return fmt.Errorf("failed to insert order: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetOrder(ctx context.Context, number string) (accrualclient.Order, error) {
+	order := accrualclient.Order{Order: number}
+	const selectOrder = `SELECT status, accrual FROM accrual_orders WHERE number = $1;`
+	if err := s.pool.QueryRow(ctx, selectOrder, number).Scan(&order.Status, &order.Accrual); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return order, ErrOrderNotFound
+		}
+		return order, fmt.Errorf("failed to get order: %w", err)
+	}
+	return order, nil
+}
+
+func (s *PostgresStorage) OrdersByStatus(
+	ctx context.Context,
+	status accrualclient.Status,
+	limit int,
+) ([]accrualclient.OrderRegistration, error) {
+	const selectOrders = `SELECT number, goods FROM accrual_orders WHERE status = $1 ORDER BY id LIMIT $2;`
+	rows, err := s.pool.Query(ctx, selectOrders, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select orders: %w", err)
+	}
+	orders, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (accrualclient.OrderRegistration, error) {
+		var order accrualclient.OrderRegistration
+		var goods []byte
+		if err := row.Scan(&order.Order, &goods); err != nil {
+			return order, err
+		}
+		return order, json.Unmarshal(goods, &order.Goods)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select orders: %w", err)
+	}
+	return orders, nil
+}
+
+func (s *PostgresStorage) SetStatus(
+	ctx context.Context,
+	number string,
+	status accrualclient.Status,
+	accrual float64,
+) error {
+	const updateOrder = `UPDATE accrual_orders SET status = $2, accrual = $3 WHERE number = $1;`
+	tag, err := s.pool.Exec(ctx, updateOrder, number, status, accrual)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOrderNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStorage) Close() {
+	s.pool.Close()
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation
+}
@@ -0,0 +1,75 @@
+package accrualsystem
+
+import (
+	"context"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+	"go.uber.org/zap"
+)
+
+const processBatch = 100
+
+// Processor moves registered orders to PROCESSING and, one interval later, calculates them.
+type Processor struct {
+	storage  Storage
+	log      *zap.SugaredLogger
+	interval time.Duration
+}
+
+func NewProcessor(storage Storage, log *zap.SugaredLogger, interval time.Duration) *Processor {
+	return &Processor{storage: storage, log: log, interval: interval}
+}
+
+func (p *Processor) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		p.calculate(ctx)
+		p.take(ctx)
+	}
+}
+
+func (p *Processor) calculate(ctx context.Context) {
+	orders, err := p.storage.OrdersByStatus(ctx, accrualclient.StatusProcessing, processBatch)
+	if err != nil {
+		p.log.Errorf("failed to get processing orders: %v", err)
+		return
+	}
+	if len(orders) == 0 {
+		return
+	}
+	rules, err := p.storage.Rules(ctx)
+	if err != nil {
+		p.log.Errorf("failed to get reward rules: %v", err)
+		return
+	}
+
+	for _, order := range orders {
+		status, accrual := calculate(order.Goods, rules)
+		if err := p.storage.SetStatus(ctx, order.Order, status, accrual); err != nil {
+			p.log.Errorf("failed to set status of order %s: %v", order.Order, err)
+			continue
+		}
+		p.log.Infow("order calculated", "order", order.Order, "status", status, "accrual", accrual)
+	}
+}
+
+func (p *Processor) take(ctx context.Context) {
+	orders, err := p.storage.OrdersByStatus(ctx, accrualclient.StatusRegistered, processBatch)
+	if err != nil {
+		p.log.Errorf("failed to get registered orders: %v", err)
+		return
+	}
+	for _, order := range orders {
+		if err := p.storage.SetStatus(ctx, order.Order, accrualclient.StatusProcessing, 0); err != nil {
+			p.log.Errorf("failed to set status of order %s: %v", order.Order, err)
+		}
+	}
+}
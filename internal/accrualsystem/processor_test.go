@@ -0,0 +1,59 @@
+package accrualsystem
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+	"go.uber.org/zap"
+)
+
+func TestProcessor(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	if err := storage.SaveRule(ctx, Rule{Match: "Bork", Reward: 10, RewardType: RewardPercent}); err != nil {
+		t.Fatalf("SaveRule: %v", err)
+	}
+	registrations := []accrualclient.OrderRegistration{
+		{Order: "12345678903", Goods: []accrualclient.Good{{Description: "Чайник Bork", Price: 7000}}},
+		{Order: "79927398713"},
+	}
+	for _, registration := range registrations {
+		if err := storage.RegisterOrder(ctx, registration); err != nil {
+			t.Fatalf("RegisterOrder: %v", err)
+		}
+	}
+	p := NewProcessor(storage, zap.NewNop().Sugar(), time.Second)
+
+	// Every step is one tick of Start: calculate the orders in PROCESSING, then take the REGISTERED ones.
+	steps := []map[string]accrualclient.Order{
+		{
+			"12345678903": {Order: "12345678903", Status: accrualclient.StatusProcessing},
+			"79927398713": {Order: "79927398713", Status: accrualclient.StatusProcessing},
+		},
+		{
+			"12345678903": {Order: "12345678903", Status: accrualclient.StatusProcessed, Accrual: 700},
+			"79927398713": {Order: "79927398713", Status: accrualclient.StatusInvalid},
+		},
+		{
+			"12345678903": {Order: "12345678903", Status: accrualclient.StatusProcessed, Accrual: 700},
+			"79927398713": {Order: "79927398713", Status: accrualclient.StatusInvalid},
+		},
+	}
+
+	for i, want := range steps {
+		p.calculate(ctx)
+		p.take(ctx)
+
+		for number, wantOrder := range want {
+			order, err := storage.GetOrder(ctx, number)
+			if err != nil {
+				t.Fatalf("tick %d: GetOrder(%s): %v", i+1, number, err)
+			}
+			if order != wantOrder {
+				t.Errorf("tick %d: order = %+v, want %+v", i+1, order, wantOrder)
+			}
+		}
+	}
+}
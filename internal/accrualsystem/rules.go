@@ -0,0 +1,65 @@
+// Package accrualsystem is a stand-in for the accrual system: it registers orders with goods, rewards
+// them by the registered rules and serves the API that gophermart polls.
+package accrualsystem
+
+import (
+	"math"
+	"strings"
+
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+)
+
+type RewardType string
+
+const (
+	// RewardPercent rewards a share of the price of a good.
+	RewardPercent RewardType = "%"
+	// RewardPoints rewards a fixed number of points per good.
+	RewardPoints RewardType = "pt"
+)
+
+// Rule rewards the goods whose description contains Match, case-insensitively.
+type Rule struct {
+	Match      string     `json:"match"`
+	Reward     float64    `json:"reward"`
+	RewardType RewardType `json:"reward_type"`
+}
+
+func (r Rule) valid() bool {
+	if strings.TrimSpace(r.Match) == "" || r.Reward < 0 {
+		return false
+	}
+	switch r.RewardType {
+	case RewardPercent:
+		return r.Reward <= 100
+	case RewardPoints:
+		return true
+	default:
+		return false
+	}
+}
+
+// calculate returns the final status and accrual of an order. Every good is rewarded by the first
+// matching rule in registration order. An order without goods is INVALID.
+func calculate(goods []accrualclient.Good, rules []Rule) (accrualclient.Status, float64) {
+	if len(goods) == 0 {
+		return accrualclient.StatusInvalid, 0
+	}
+
+	var accrual float64
+	for _, good := range goods {
+		description := strings.ToLower(good.Description)
+		for _, rule := range rules {
+			if !strings.Contains(description, strings.ToLower(rule.Match)) {
+				continue
+			}
+			if rule.RewardType == RewardPercent {
+				accrual += good.Price * rule.Reward / 100
+			} else {
+				accrual += rule.Reward
+			}
+			break
+		}
+	}
+	return accrualclient.StatusProcessed, math.Round(accrual*100) / 100
+}
@@ -0,0 +1,76 @@
+package accrualsystem
+
+import (
+	"testing"
+
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+)
+
+func TestRuleValid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{name: "percent", rule: Rule{Match: "Bork", Reward: 10, RewardType: RewardPercent}, want: true},
+		{name: "whole price", rule: Rule{Match: "Bork", Reward: 100, RewardType: RewardPercent}, want: true},
+		{name: "points", rule: Rule{Match: "Bork", Reward: 500, RewardType: RewardPoints}, want: true},
+		{name: "zero reward", rule: Rule{Match: "Bork", RewardType: RewardPoints}, want: true},
+		{name: "empty match", rule: Rule{Match: " ", Reward: 10, RewardType: RewardPercent}},
+		{name: "negative reward", rule: Rule{Match: "Bork", Reward: -1, RewardType: RewardPoints}},
+		{name: "percent over 100", rule: Rule{Match: "Bork", Reward: 100.5, RewardType: RewardPercent}},
+		{name: "unknown type", rule: Rule{Match: "Bork", Reward: 10, RewardType: "pct"}},
+		{name: "missing type", rule: Rule{Match: "Bork", Reward: 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.valid(); got != tt.want {
+				t.Errorf("valid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	rules := []Rule{
+		{Match: "Bork", Reward: 10, RewardType: RewardPercent},
+		{Match: "чайник", Reward: 500, RewardType: RewardPoints},
+		{Match: "Miele", Reward: 33.333, RewardType: RewardPercent},
+	}
+
+	tests := []struct {
+		name        string
+		goods       []accrualclient.Good
+		wantStatus  accrualclient.Status
+		wantAccrual float64
+	}{
+		{name: "no goods", wantStatus: accrualclient.StatusInvalid},
+		{name: "percent of the price", goods: []accrualclient.Good{{Description: "Утюг Bork", Price: 7000}},
+			wantStatus: accrualclient.StatusProcessed, wantAccrual: 700},
+		{name: "points per good", goods: []accrualclient.Good{{Description: "Чайник Tefal", Price: 2000}},
+			wantStatus: accrualclient.StatusProcessed, wantAccrual: 500},
+		{name: "first matching rule wins", goods: []accrualclient.Good{{Description: "Чайник Bork", Price: 7000}},
+			wantStatus: accrualclient.StatusProcessed, wantAccrual: 700},
+		{name: "case-insensitive match", goods: []accrualclient.Good{{Description: "bork BORK", Price: 100}},
+			wantStatus: accrualclient.StatusProcessed, wantAccrual: 10},
+		{name: "rounded to cents", goods: []accrualclient.Good{{Description: "Miele", Price: 10}},
+			wantStatus: accrualclient.StatusProcessed, wantAccrual: 3.33},
+		{name: "unmatched goods earn nothing", goods: []accrualclient.Good{{Description: "Лампа", Price: 100}},
+			wantStatus: accrualclient.StatusProcessed},
+		{name: "goods add up", goods: []accrualclient.Good{
+			{Description: "Утюг Bork", Price: 7000},
+			{Description: "Чайник Tefal", Price: 2000},
+			{Description: "Лампа", Price: 100},
+		}, wantStatus: accrualclient.StatusProcessed, wantAccrual: 1200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, accrual := calculate(tt.goods, rules)
+			if status != tt.wantStatus || accrual != tt.wantAccrual {
+				t.Errorf("calculate() = %s, %v, want %s, %v", status, accrual, tt.wantStatus, tt.wantAccrual)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package accrualsystem
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+	"github.com/tiunovvv/gophermart/internal/middleware"
+	"github.com/tiunovvv/gophermart/internal/ordernum"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const bodyLimit = 64 << 10
+
+// numbers is the check the accrual system applies to the orders it registers.
+var numbers = ordernum.Luhn{Bounds: ordernum.Bounds{MinLen: 2, MaxLen: 32}}
+
+type Server struct {
+	storage Storage
+	log     *zap.SugaredLogger
+	// limiter is shared by all clients, nil means no limit.
+	limiter   *rate.Limiter
+	perMinute int
+}
+
+// NewServer serves the accrual API from storage. Order requests beyond perMinute per minute are answered
+// with 429, 0 disables the limit.
+func NewServer(storage Storage, log *zap.SugaredLogger, perMinute int) *Server {
+	s := &Server{storage: storage, log: log, perMinute: perMinute}
+	if perMinute > 0 {
+		s.limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), perMinute)
+	}
+	return s
+}
+
+func (s *Server) Routes() *gin.Engine {
+	router := gin.New()
+	router.Use(middleware.GinLogger(s.log))
+
+	jsonBody := []gin.HandlerFunc{middleware.RequireContentType(gin.MIMEJSON), middleware.LimitBody(bodyLimit)}
+
+	router.POST("/api/goods", append(jsonBody, s.saveRule)...)
+	router.POST("/api/orders", append(jsonBody, s.rateLimit, s.registerOrder)...)
+	router.GET("/api/orders/:number", s.rateLimit, s.getOrder)
+
+	return router
+}
+
+// rateLimit answers 429 with Retry-After once the requests of the current minute are used up.
+func (s *Server) rateLimit(c *gin.Context) {
+	if s.limiter == nil {
+		c.Next()
+		return
+	}
+
+	reservation := s.limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		c.String(http.StatusTooManyRequests, "No more than %d requests per minute allowed", s.perMinute)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func (s *Server) saveRule(c *gin.Context) {
+	var rule Rule
+	if err := c.ShouldBindJSON(&rule); err != nil || !rule.valid() {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	err := s.storage.SaveRule(c, rule)
+	switch {
+	case errors.Is(err, ErrRuleExists):
+		c.AbortWithStatus(http.StatusConflict)
+	case err != nil:
+		s.log.Errorf("failed to save rule: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	default:
+		c.Status(http.StatusOK)
+	}
+}
+
+func (s *Server) registerOrder(c *gin.Context) {
+	var registration accrualclient.OrderRegistration
+	if err := c.ShouldBindJSON(&registration); err != nil || !numbers.Valid(registration.Order) {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	for _, good := range registration.Goods {
+		if good.Price < 0 {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := s.storage.RegisterOrder(c, registration)
+	switch {
+	case errors.Is(err, ErrOrderExists):
+		c.AbortWithStatus(http.StatusConflict)
+	case err != nil:
+		s.log.Errorf("failed to register order: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	default:
+		c.Status(http.StatusAccepted)
+	}
+}
+
+func (s *Server) getOrder(c *gin.Context) {
+	order, err := s.storage.GetOrder(c, c.Param("number"))
+	switch {
+	case errors.Is(err, ErrOrderNotFound):
+		c.Status(http.StatusNoContent)
+	case err != nil:
+		s.log.Errorf("failed to get order: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	default:
+		if order.Status != accrualclient.StatusProcessed {
+			order.Accrual = 0
+		}
+		c.JSON(http.StatusOK, order)
+	}
+}
@@ -0,0 +1,94 @@
+package accrualsystem
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func newTestServer(t *testing.T, storage Storage, perMinute int) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	return NewServer(storage, zap.NewNop().Sugar(), perMinute).Routes()
+}
+
+func serve(router *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRegisterOrder(t *testing.T) {
+	const goods = `"goods": [{"description": "Чайник Bork", "price": 7000}]`
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "registered", body: `{"order": "12345678903", ` + goods + `}`, wantStatus: http.StatusAccepted},
+		{name: "already registered", body: `{"order": "79927398713", ` + goods + `}`, wantStatus: http.StatusConflict},
+		{name: "wrong check digit", body: `{"order": "12345678904", ` + goods + `}`, wantStatus: http.StatusBadRequest},
+		{name: "not a number", body: `{"order": "1234-5678", ` + goods + `}`, wantStatus: http.StatusBadRequest},
+		{name: "empty number", body: `{"order": "", ` + goods + `}`, wantStatus: http.StatusBadRequest},
+		{name: "negative price", body: `{"order": "12345678903", "goods": [{"description": "Bork", "price": -1}]}`,
+			wantStatus: http.StatusBadRequest},
+		{name: "malformed body", body: `{"order": `, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestServer(t, NewMemoryStorage(), 0)
+			rec := serve(router, http.MethodPost, "/api/orders", `{"order": "79927398713"}`)
+			if rec.Code != http.StatusAccepted {
+				t.Fatalf("setup registration status = %d", rec.Code)
+			}
+
+			if rec := serve(router, http.MethodPost, "/api/orders", tt.body); rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name           string
+		perMinute      int
+		requests       int
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{name: "within the limit", perMinute: 2, requests: 2, wantStatus: http.StatusNoContent},
+		{name: "over the limit", perMinute: 2, requests: 3, wantStatus: http.StatusTooManyRequests,
+			wantRetryAfter: "30"},
+		{name: "one per minute", perMinute: 1, requests: 2, wantStatus: http.StatusTooManyRequests,
+			wantRetryAfter: "60"},
+		{name: "no limit", perMinute: 0, requests: 100, wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestServer(t, NewMemoryStorage(), tt.perMinute)
+
+			var rec *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				rec = serve(router, http.MethodGet, "/api/orders/12345678903", "")
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package accrualsystem
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+)
+
+var (
+	ErrRuleExists    = errors.New("reward rule already registered")
+	ErrOrderExists   = errors.New("order already registered")
+	ErrOrderNotFound = errors.New("order not found")
+)
+
+// Storage keeps reward rules and registered orders, see NewMemoryStorage and NewPostgresStorage.
+type Storage interface {
+	// SaveRule returns ErrRuleExists for a known match.
+	SaveRule(ctx context.Context, rule Rule) error
+	// Rules returns the rules in registration order.
+	Rules(ctx context.Context) ([]Rule, error)
+	// RegisterOrder stores a REGISTERED order or returns ErrOrderExists.
+	RegisterOrder(ctx context.Context, registration accrualclient.OrderRegistration) error
+	// GetOrder returns ErrOrderNotFound for an unknown order.
+	GetOrder(ctx context.Context, number string) (accrualclient.Order, error)
+	// OrdersByStatus returns up to limit orders in status, oldest first.
+	OrdersByStatus(ctx context.Context, status accrualclient.Status, limit int) ([]accrualclient.OrderRegistration, error)
+	SetStatus(ctx context.Context, number string, status accrualclient.Status, accrual float64) error
+	Close()
+}
@@ -1,51 +1,569 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/ordernum"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	RunAddress           string
-	DatabaseDSN          string
-	AccrualSystemAddress string
+	RunAddress           string `yaml:"run_address"`
+	DatabaseDSN          string `yaml:"database_uri"`
+	AccrualSystemAddress string `yaml:"accrual_system_address"`
+	ConfigFile           string `yaml:"-"`
+
+	Server  ServerConfig  `yaml:"server"`
+	Auth    AuthConfig    `yaml:"auth"`
+	Accrual AccrualConfig `yaml:"accrual"`
+	Log     LogConfig     `yaml:"log"`
+	Admin   AdminConfig   `yaml:"admin"`
+
+	Expiration ExpirationConfig `yaml:"expiration"`
+	Withdraw   WithdrawConfig   `yaml:"withdraw"`
+	Orders     OrdersConfig     `yaml:"orders"`
+}
+
+type ServerConfig struct {
+	HandlerTimeout  time.Duration `yaml:"handler_timeout"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// DrainDelay is how long /readyz reports not ready before the server stops accepting connections,
+	// so that load balancers notice and stop routing traffic.
+	DrainDelay time.Duration `yaml:"drain_delay"`
+}
+
+type AuthConfig struct {
+	BcryptCost   int           `yaml:"bcrypt_cost"`
+	CookieMaxAge time.Duration `yaml:"cookie_max_age"`
+}
+
+type AccrualConfig struct {
+	// WorkerCount is the initial pool size, the pool is then autoscaled between MinWorkers and MaxWorkers
+	// every ScaleInterval.
+	WorkerCount   int           `yaml:"worker_count"`
+	MinWorkers    int           `yaml:"min_workers"`
+	MaxWorkers    int           `yaml:"max_workers"`
+	ScaleInterval time.Duration `yaml:"scale_interval"`
+	BatchSize     int           `yaml:"batch_size"`
+	PollInterval  time.Duration `yaml:"poll_interval"`
+	// RateLimit caps requests per second to the accrual system, 0 disables the limit.
+	RateLimit      float64       `yaml:"rate_limit"`
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// BreakerThreshold is the number of consecutive failed requests that opens the circuit breaker
+	// for BreakerCoolDown, 0 disables the breaker.
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerCoolDown  time.Duration `yaml:"breaker_cool_down"`
+	// Accrual results are stored in batches of ResultBatchSize or every ResultFlushInterval,
+	// whichever comes first.
+	ResultBatchSize     int           `yaml:"result_batch_size"`
+	ResultFlushInterval time.Duration `yaml:"result_flush_interval"`
+	// CallbackSecret enables POST /api/internal/accrual/callback signed with this HMAC secret.
+	// Orders are then polled only when no callback arrived within CallbackTimeout of the upload
+	// or of the last callback.
+	CallbackSecret  string        `yaml:"callback_secret"`
+	CallbackTimeout time.Duration `yaml:"callback_timeout"`
+	// RegisterAttempts is how many times an order uploaded with goods is sent to the accrual system
+	// before its registration is marked FAILED.
+	RegisterAttempts int `yaml:"register_attempts"`
+}
+
+type ExpirationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Months is the lifetime of accrued points, counted from the accrual.
+	Months int `yaml:"months"`
+	// CheckInterval is how often expired lots are written off.
+	CheckInterval time.Duration `yaml:"check_interval"`
+	// WarnWindow is how far ahead the balance reports points as expiring soon.
+	WarnWindow time.Duration `yaml:"warn_window"`
+}
+
+type WithdrawConfig struct {
+	// ReviewThreshold is the smallest sum that waits for an admin approval,
+	// smaller withdrawals complete at once. 0 disables the review.
+	ReviewThreshold float64 `yaml:"review_threshold"`
+}
+
+type OrdersConfig struct {
+	// Algorithm is the check-digit scheme of order numbers, see the ordernum package.
+	Algorithm string `yaml:"algorithm"`
+	MinLength int    `yaml:"min_length"`
+	MaxLength int    `yaml:"max_length"`
+}
+
+func (c OrdersConfig) Validator() (ordernum.Validator, error) {
+	return ordernum.New(c.Algorithm, ordernum.Bounds{MinLen: c.MinLength, MaxLen: c.MaxLength})
+}
+
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+	// Component levels override Level for one subsystem, empty means inherit.
+	HTTPLevel    string `yaml:"http_level"`
+	DBLevel      string `yaml:"db_level"`
+	AccrualLevel string `yaml:"accrual_level"`
+	// Sampling keeps the first SamplingInitial entries with the same message per second
+	// and then every SamplingThereafter-th one, 0 disables sampling.
+	SamplingInitial    int `yaml:"sampling_initial"`
+	SamplingThereafter int `yaml:"sampling_thereafter"`
+}
+
+func (c LogConfig) ComponentLevels() map[string]string {
+	return map[string]string{
+		"http":    c.HTTPLevel,
+		"db":      c.DBLevel,
+		"accrual": c.AccrualLevel,
+	}
+}
+
+type AdminConfig struct {
+	// Accounts maps operator login to password for HTTP basic auth on /api/admin.
+	// Admin routes are disabled when empty.
+	Accounts Accounts `yaml:"accounts"`
+}
+
+// Accounts is set from flags and env as "login:password,login2:password2".
+type Accounts map[string]string
+
+func (a *Accounts) String() string {
+	if a == nil {
+		return ""
+	}
+	logins := make([]string, 0, len(*a))
+	for login := range *a {
+		logins = append(logins, login)
+	}
+	sort.Strings(logins)
+	return strings.Join(logins, ",")
+}
+
+func (a *Accounts) Set(value string) error {
+	accounts := make(Accounts)
+	for _, pair := range strings.Split(value, ",") {
+		if pair == "" {
+			continue
+		}
+		login, password, ok := strings.Cut(pair, ":")
+		if !ok || login == "" || password == "" {
+			return fmt.Errorf("account %q must be login:password", pair)
+		}
+		accounts[login] = password
+	}
+	*a = accounts
+	return nil
+}
+
+func defaultConfig() *Config {
+	const (
+		handlerTimeout  = 5 * time.Second
+		shutdownTimeout = 5 * time.Second
+		drainDelay      = 5 * time.Second
+		cookieMaxAge    = 30 * 24 * time.Hour
+		bcryptCost      = 10
+		workerCount     = 3
+		minWorkers      = 1
+		maxWorkers      = 10
+		scaleInterval   = 10 * time.Second
+		batchSize       = 100
+		pollInterval    = 5 * time.Second
+		accrualTimeout  = 5 * time.Second
+		breakerFailures = 5
+		breakerCoolDown = 30 * time.Second
+		resultBatchSize = 100
+		resultFlush     = 500 * time.Millisecond
+		callbackTimeout = time.Minute
+		registerTries   = 10
+		expireMonths    = 12
+		expireInterval  = time.Hour
+		expireWarn      = 30 * 24 * time.Hour
+		orderMinLength  = 2
+		orderMaxLength  = 32
+	)
+
+	return &Config{
+		RunAddress:           "localhost:8080",
+		AccrualSystemAddress: "http://localhost:8000",
+		Server: ServerConfig{
+			HandlerTimeout:  handlerTimeout,
+			ReadTimeout:     handlerTimeout,
+			WriteTimeout:    handlerTimeout,
+			ShutdownTimeout: shutdownTimeout,
+			DrainDelay:      drainDelay,
+		},
+		Auth: AuthConfig{
+			BcryptCost:   bcryptCost,
+			CookieMaxAge: cookieMaxAge,
+		},
+		Accrual: AccrualConfig{
+			WorkerCount:         workerCount,
+			MinWorkers:          minWorkers,
+			MaxWorkers:          maxWorkers,
+			ScaleInterval:       scaleInterval,
+			BatchSize:           batchSize,
+			PollInterval:        pollInterval,
+			RequestTimeout:      accrualTimeout,
+			BreakerThreshold:    breakerFailures,
+			BreakerCoolDown:     breakerCoolDown,
+			ResultBatchSize:     resultBatchSize,
+			ResultFlushInterval: resultFlush,
+			CallbackTimeout:     callbackTimeout,
+			RegisterAttempts:    registerTries,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogFormatConsole,
+		},
+		Orders: OrdersConfig{
+			Algorithm: ordernum.AlgorithmLuhn,
+			MinLength: orderMinLength,
+			MaxLength: orderMaxLength,
+		},
+		Expiration: ExpirationConfig{
+			Months:        expireMonths,
+			CheckInterval: expireInterval,
+			WarnWindow:    expireWarn,
+		},
+	}
+}
+
+type binding struct {
+	flag  string
+	env   string
+	usage string
+	bind  func(fs *flag.FlagSet, cfg *Config, name string, usage string)
 }
 
-func GetConfig() *Config {
-	runAddress := flag.String("a", "localhost:8080", "runAddress")
-	databaseDSN := flag.String("d", "", "databaseDSN")
-	accrualSystemAddress := flag.String("r", "http://localhost:8000", "accrualSystemAddress")
-	flag.Parse()
+var bindings = []binding{
+	{"c", "CONFIG", "path to a YAML or JSON config file", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.StringVar(&cfg.ConfigFile, n, cfg.ConfigFile, u)
+	}},
+	{"a", "RUN_ADDRESS", "runAddress", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.StringVar(&cfg.RunAddress, n, cfg.RunAddress, u)
+	}},
+	{"d", "DATABASE_URI", "databaseDSN", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.StringVar(&cfg.DatabaseDSN, n, cfg.DatabaseDSN, u)
+	}},
+	{"r", "ACCRUAL_SYSTEM_ADDRESS", "accrualSystemAddress", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.StringVar(&cfg.AccrualSystemAddress, n, cfg.AccrualSystemAddress, u)
+	}},
+	{"handler-timeout", "HANDLER_TIMEOUT", "timeout for a single request handler", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Server.HandlerTimeout, n, cfg.Server.HandlerTimeout, u)
+	}},
+	{"read-timeout", "READ_TIMEOUT", "HTTP server read timeout", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Server.ReadTimeout, n, cfg.Server.ReadTimeout, u)
+	}},
+	{"write-timeout", "WRITE_TIMEOUT", "HTTP server write timeout", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Server.WriteTimeout, n, cfg.Server.WriteTimeout, u)
+	}},
+	{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "HTTP server graceful shutdown timeout", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Server.ShutdownTimeout, n, cfg.Server.ShutdownTimeout, u)
+	}},
+	{"drain-delay", "DRAIN_DELAY", "time /readyz fails before the HTTP server shuts down", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Server.DrainDelay, n, cfg.Server.DrainDelay, u)
+	}},
+	{"bcrypt-cost", "BCRYPT_COST", "bcrypt cost for password hashes", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Auth.BcryptCost, n, cfg.Auth.BcryptCost, u)
+	}},
+	{"cookie-max-age", "COOKIE_MAX_AGE", "lifetime of the authorization cookie", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Auth.CookieMaxAge, n, cfg.Auth.CookieMaxAge, u)
+	}},
+	{"workers", "ACCRUAL_WORKER_COUNT", "number of accrual workers", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Accrual.WorkerCount, n, cfg.Accrual.WorkerCount, u)
+	}},
+	{"min-workers", "ACCRUAL_MIN_WORKERS", "lower bound of the autoscaled accrual worker pool", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Accrual.MinWorkers, n, cfg.Accrual.MinWorkers, u)
+	}},
+	{"max-workers", "ACCRUAL_MAX_WORKERS", "upper bound of the autoscaled accrual worker pool", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Accrual.MaxWorkers, n, cfg.Accrual.MaxWorkers, u)
+	}},
+	{"scale-interval", "ACCRUAL_SCALE_INTERVAL", "interval between accrual worker pool autoscaling decisions", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Accrual.ScaleInterval, n, cfg.Accrual.ScaleInterval, u)
+	}},
+	{"batch-size", "ACCRUAL_BATCH_SIZE", "orders fetched per accrual poll", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Accrual.BatchSize, n, cfg.Accrual.BatchSize, u)
+	}},
+	{"poll-interval", "ACCRUAL_POLL_INTERVAL", "interval between fallback sweeps for orders to send to accrual", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Accrual.PollInterval, n, cfg.Accrual.PollInterval, u)
+	}},
+	{"rate-limit", "ACCRUAL_RATE_LIMIT", "requests per second to accrual, 0 is unlimited", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.Float64Var(&cfg.Accrual.RateLimit, n, cfg.Accrual.RateLimit, u)
+	}},
+	{"accrual-timeout", "ACCRUAL_REQUEST_TIMEOUT", "timeout of a single request to accrual", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Accrual.RequestTimeout, n, cfg.Accrual.RequestTimeout, u)
+	}},
+	{"breaker-threshold", "ACCRUAL_BREAKER_THRESHOLD", "consecutive accrual failures that open the circuit breaker, 0 disables it", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Accrual.BreakerThreshold, n, cfg.Accrual.BreakerThreshold, u)
+	}},
+	{"breaker-cool-down", "ACCRUAL_BREAKER_COOL_DOWN", "time the accrual circuit breaker stays open before a probe request", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Accrual.BreakerCoolDown, n, cfg.Accrual.BreakerCoolDown, u)
+	}},
+	{"result-batch-size", "ACCRUAL_RESULT_BATCH_SIZE", "accrual results stored in one batch", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Accrual.ResultBatchSize, n, cfg.Accrual.ResultBatchSize, u)
+	}},
+	{"result-flush-interval", "ACCRUAL_RESULT_FLUSH_INTERVAL", "longest time an accrual result waits for its batch", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Accrual.ResultFlushInterval, n, cfg.Accrual.ResultFlushInterval, u)
+	}},
+	{"callback-secret", "ACCRUAL_CALLBACK_SECRET", "HMAC secret of accrual callbacks, empty disables them", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.StringVar(&cfg.Accrual.CallbackSecret, n, cfg.Accrual.CallbackSecret, u)
+	}},
+	{"callback-timeout", "ACCRUAL_CALLBACK_TIMEOUT", "time to wait for an accrual callback before polling the order", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Accrual.CallbackTimeout, n, cfg.Accrual.CallbackTimeout, u)
+	}},
+	{"register-attempts", "ACCRUAL_REGISTER_ATTEMPTS", "attempts to register an order with goods in accrual", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Accrual.RegisterAttempts, n, cfg.Accrual.RegisterAttempts, u)
+	}},
+	{"log-level", "LOG_LEVEL", "log level", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.StringVar(&cfg.Log.Level, n, cfg.Log.Level, u)
+	}},
+	{"log-format", "LOG_FORMAT", "log format, json or console", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.StringVar(&cfg.Log.Format, n, cfg.Log.Format, u)
+	}},
+	{"log-level-http", "LOG_LEVEL_HTTP", "log level of the http component", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.StringVar(&cfg.Log.HTTPLevel, n, cfg.Log.HTTPLevel, u)
+	}},
+	{"log-level-db", "LOG_LEVEL_DB", "log level of the db component", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.StringVar(&cfg.Log.DBLevel, n, cfg.Log.DBLevel, u)
+	}},
+	{"log-level-accrual", "LOG_LEVEL_ACCRUAL", "log level of the accrual component", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.StringVar(&cfg.Log.AccrualLevel, n, cfg.Log.AccrualLevel, u)
+	}},
+	{"log-sampling-initial", "LOG_SAMPLING_INITIAL", "log entries per message and second before sampling, 0 disables", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Log.SamplingInitial, n, cfg.Log.SamplingInitial, u)
+	}},
+	{"log-sampling-thereafter", "LOG_SAMPLING_THEREAFTER", "keep every n-th log entry after the initial ones", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Log.SamplingThereafter, n, cfg.Log.SamplingThereafter, u)
+	}},
+	{"admin-accounts", "ADMIN_ACCOUNTS", "admin accounts as login:password,login2:password2", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.Var(&cfg.Admin.Accounts, n, u)
+	}},
+	{"expiration-enabled", "EXPIRATION_ENABLED", "expire accrued points after expiration-months", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.BoolVar(&cfg.Expiration.Enabled, n, cfg.Expiration.Enabled, u)
+	}},
+	{"expiration-months", "EXPIRATION_MONTHS", "lifetime of accrued points in months", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Expiration.Months, n, cfg.Expiration.Months, u)
+	}},
+	{"expiration-interval", "EXPIRATION_INTERVAL", "interval between expiration runs", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Expiration.CheckInterval, n, cfg.Expiration.CheckInterval, u)
+	}},
+	{"expiration-warn-window", "EXPIRATION_WARN_WINDOW", "points expiring within this window are reported as expiring soon", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.DurationVar(&cfg.Expiration.WarnWindow, n, cfg.Expiration.WarnWindow, u)
+	}},
+	{"withdraw-review-threshold", "WITHDRAW_REVIEW_THRESHOLD", "withdrawals of at least this sum wait for review, 0 disables", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.Float64Var(&cfg.Withdraw.ReviewThreshold, n, cfg.Withdraw.ReviewThreshold, u)
+	}},
+	{"order-algorithm", "ORDER_ALGORITHM", "order number check-digit scheme: luhn, verhoeff, damm or iso7064-mod97-10", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.StringVar(&cfg.Orders.Algorithm, n, cfg.Orders.Algorithm, u)
+	}},
+	{"order-min-length", "ORDER_MIN_LENGTH", "minimal number of digits in an order number", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Orders.MinLength, n, cfg.Orders.MinLength, u)
+	}},
+	{"order-max-length", "ORDER_MAX_LENGTH", "maximal number of digits in an order number", func(fs *flag.FlagSet, cfg *Config, n, u string) {
+		fs.IntVar(&cfg.Orders.MaxLength, n, cfg.Orders.MaxLength, u)
+	}},
+}
+
+// GetConfig builds the configuration from the command line and the environment.
+// Values are applied in order of increasing precedence:
+// defaults, config file (-c or CONFIG), flags, environment variables.
+func GetConfig() (*Config, error) {
+	return Load(os.Args[1:])
+}
+
+func Load(args []string) (*Config, error) {
+	path, err := configPath(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := defaultConfig()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	fs := newFlagSet(cfg)
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	for _, b := range bindings {
+		if value, ok := os.LookupEnv(b.env); ok {
+			if err := fs.Set(b.flag, value); err != nil {
+				return nil, fmt.Errorf("invalid value %q for %s: %w", value, b.env, err)
+			}
+		}
+	}
+	cfg.ConfigFile = path
 
-	config := Config{
-		RunAddress:           getRunAddress(runAddress),
-		DatabaseDSN:          getDatabaseURI(databaseDSN),
-		AccrualSystemAddress: getAccrualSystemAddress(accrualSystemAddress),
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
+	return cfg, nil
+}
 
-	return &config
+func newFlagSet(cfg *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	for _, b := range bindings {
+		b.bind(fs, cfg, b.flag, b.usage)
+	}
+	return fs
 }
 
-func getRunAddress(runAddress *string) string {
-	if envRunAddress, ok := os.LookupEnv("RUN_ADDRESS"); ok {
-		return envRunAddress
+func configPath(args []string) (string, error) {
+	var cfg Config
+	fs := newFlagSet(&cfg)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil && !errors.Is(err, flag.ErrHelp) {
+		return "", fmt.Errorf("failed to parse flags: %w", err)
 	}
 
-	return *runAddress
+	if envConfig, ok := os.LookupEnv("CONFIG"); ok {
+		return envConfig, nil
+	}
+	return cfg.ConfigFile, nil
 }
 
-func getDatabaseURI(flagBaseURL *string) string {
-	if envDatabaseURI, ok := os.LookupEnv("DATABASE_URI"); ok {
-		return envDatabaseURI
+// readFile decodes YAML or JSON; JSON is accepted as the YAML superset it is.
+func (c *Config) readFile(path string) error {
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml", ".json":
+	default:
+		return fmt.Errorf("unsupported config file extension %q", ext)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	return *flagBaseURL
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to decode config file %s: %w", path, err)
+	}
+	return nil
 }
 
-func getAccrualSystemAddress(filePath *string) string {
-	if envAccrualSystemAddress, ok := os.LookupEnv("ACCRUAL_SYSTEM_ADDRESS"); ok {
-		return envAccrualSystemAddress
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.RunAddress == "" {
+		errs = append(errs, errors.New("run address is required"))
+	}
+	if c.DatabaseDSN == "" {
+		errs = append(errs, errors.New("database URI is required"))
+	}
+	if u, err := url.Parse(c.AccrualSystemAddress); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("accrual system address %q must be an absolute URL", c.AccrualSystemAddress))
+	}
+
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.handler_timeout", c.Server.HandlerTimeout},
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"auth.cookie_max_age", c.Auth.CookieMaxAge},
+		{"accrual.poll_interval", c.Accrual.PollInterval},
+		{"accrual.request_timeout", c.Accrual.RequestTimeout},
+		{"accrual.scale_interval", c.Accrual.ScaleInterval},
+		{"accrual.breaker_cool_down", c.Accrual.BreakerCoolDown},
+		{"accrual.result_flush_interval", c.Accrual.ResultFlushInterval},
+		{"accrual.callback_timeout", c.Accrual.CallbackTimeout},
+	} {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", d.name, d.value))
+		}
+	}
+
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, fmt.Errorf("server.drain_delay must not be negative, got %s", c.Server.DrainDelay))
+	}
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("auth.bcrypt_cost must be between %d and %d, got %d",
+			bcrypt.MinCost, bcrypt.MaxCost, c.Auth.BcryptCost))
+	}
+	if c.Accrual.WorkerCount < 1 {
+		errs = append(errs, fmt.Errorf("accrual.worker_count must be at least 1, got %d", c.Accrual.WorkerCount))
+	}
+	if c.Accrual.MinWorkers < 1 || c.Accrual.MaxWorkers < c.Accrual.MinWorkers {
+		errs = append(errs, fmt.Errorf("accrual workers must satisfy 1 <= min_workers <= max_workers, got %d..%d",
+			c.Accrual.MinWorkers, c.Accrual.MaxWorkers))
+	}
+	if c.Accrual.BatchSize < 1 {
+		errs = append(errs, fmt.Errorf("accrual.batch_size must be at least 1, got %d", c.Accrual.BatchSize))
+	}
+	if c.Accrual.RegisterAttempts < 1 {
+		errs = append(errs, fmt.Errorf("accrual.register_attempts must be at least 1, got %d", c.Accrual.RegisterAttempts))
+	}
+	if c.Accrual.ResultBatchSize < 1 {
+		errs = append(errs, fmt.Errorf("accrual.result_batch_size must be at least 1, got %d", c.Accrual.ResultBatchSize))
+	}
+	if c.Accrual.BreakerThreshold < 0 {
+		errs = append(errs, fmt.Errorf("accrual.breaker_threshold must not be negative, got %d", c.Accrual.BreakerThreshold))
+	}
+	if c.Accrual.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("accrual.rate_limit must not be negative, got %v", c.Accrual.RateLimit))
+	}
+	if _, err := zapcore.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	for component, level := range c.Log.ComponentLevels() {
+		if level == "" {
+			continue
+		}
+		if _, err := zapcore.ParseLevel(level); err != nil {
+			errs = append(errs, fmt.Errorf("log.%s_level: %w", component, err))
+		}
+	}
+	if c.Log.Format != LogFormatJSON && c.Log.Format != LogFormatConsole {
+		errs = append(errs, fmt.Errorf("log.format must be %s or %s, got %q", LogFormatJSON, LogFormatConsole, c.Log.Format))
+	}
+	if c.Log.SamplingInitial < 0 || c.Log.SamplingThereafter < 0 {
+		errs = append(errs, errors.New("log sampling values must not be negative"))
+	}
+	if _, err := c.Orders.Validator(); err != nil {
+		errs = append(errs, fmt.Errorf("orders: %w", err))
+	}
+	if c.Withdraw.ReviewThreshold < 0 {
+		errs = append(errs, fmt.Errorf("withdraw.review_threshold must not be negative, got %v", c.Withdraw.ReviewThreshold))
+	}
+	if c.Expiration.Enabled {
+		if c.Expiration.Months < 1 {
+			errs = append(errs, fmt.Errorf("expiration.months must be at least 1, got %d", c.Expiration.Months))
+		}
+		if c.Expiration.CheckInterval <= 0 {
+			errs = append(errs, fmt.Errorf("expiration.check_interval must be positive, got %s", c.Expiration.CheckInterval))
+		}
+		if c.Expiration.WarnWindow < 0 {
+			errs = append(errs, fmt.Errorf("expiration.warn_window must not be negative, got %s", c.Expiration.WarnWindow))
+		}
 	}
 
-	return *filePath
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
 }
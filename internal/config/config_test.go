@@ -0,0 +1,259 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDSN = "postgres://localhost/test"
+
+// writeConfigFile writes content to a file named name in a temporary directory.
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const file = `
+run_address: file:8080
+log:
+  level: warn
+accrual:
+  worker_count: 4
+  poll_interval: 7s
+`
+
+	tests := []struct {
+		name        string
+		file        bool
+		args        []string
+		env         map[string]string
+		wantAddress string
+		wantLevel   string
+		wantWorkers int
+		wantPoll    time.Duration
+	}{
+		{name: "defaults", wantAddress: "localhost:8080", wantLevel: "info", wantWorkers: 3, wantPoll: 5 * time.Second},
+		{name: "file over defaults", file: true,
+			wantAddress: "file:8080", wantLevel: "warn", wantWorkers: 4, wantPoll: 7 * time.Second},
+		{name: "flags over file", file: true, args: []string{"-a", "flag:8080", "-workers", "5"},
+			wantAddress: "flag:8080", wantLevel: "warn", wantWorkers: 5, wantPoll: 7 * time.Second},
+		{name: "env over flags", file: true, args: []string{"-a", "flag:8080", "-log-level", "debug"},
+			env:         map[string]string{"RUN_ADDRESS": "env:8080", "ACCRUAL_POLL_INTERVAL": "9s"},
+			wantAddress: "env:8080", wantLevel: "debug", wantWorkers: 4, wantPoll: 9 * time.Second},
+		{name: "config file from env", env: map[string]string{"CONFIG": ""},
+			wantAddress: "file:8080", wantLevel: "warn", wantWorkers: 4, wantPoll: 7 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, "config.yaml", file)
+			args := append([]string{"-d", testDSN}, tt.args...)
+			if tt.file {
+				args = append(args, "-c", path)
+			}
+			for name, value := range tt.env {
+				if name == "CONFIG" {
+					value = path
+				}
+				t.Setenv(name, value)
+			}
+
+			cfg, err := Load(args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.RunAddress != tt.wantAddress {
+				t.Errorf("run address = %q, want %q", cfg.RunAddress, tt.wantAddress)
+			}
+			if cfg.Log.Level != tt.wantLevel {
+				t.Errorf("log level = %q, want %q", cfg.Log.Level, tt.wantLevel)
+			}
+			if cfg.Accrual.WorkerCount != tt.wantWorkers {
+				t.Errorf("worker count = %d, want %d", cfg.Accrual.WorkerCount, tt.wantWorkers)
+			}
+			if cfg.Accrual.PollInterval != tt.wantPoll {
+				t.Errorf("poll interval = %s, want %s", cfg.Accrual.PollInterval, tt.wantPoll)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{name: "yaml", file: "config.yml", content: "server:\n  drain_delay: 1s\n"},
+		{name: "json", file: "config.json", content: `{"server": {"drain_delay": "1s"}}`},
+		{name: "empty file", file: "config.yaml"},
+		{name: "unknown yaml field", file: "config.yaml", content: "server:\n  drain_dealy: 1s\n",
+			wantErr: "field drain_dealy not found"},
+		{name: "unknown json field", file: "config.json", content: `{"acrual": {"worker_count": 2}}`,
+			wantErr: "field acrual not found"},
+		{name: "malformed duration", file: "config.yaml", content: "server:\n  drain_delay: soon\n",
+			wantErr: "failed to decode config file"},
+		{name: "unsupported extension", file: "config.toml", content: "run_address = 'x'",
+			wantErr: `unsupported config file extension ".toml"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, tt.file, tt.content)
+
+			_, err := Load([]string{"-d", testDSN, "-c", path})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccountsSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Accounts
+		wantErr bool
+	}{
+		{name: "one account", value: "admin:secret", want: Accounts{"admin": "secret"}},
+		{name: "two accounts", value: "admin:secret,ops:p:a:ss",
+			want: Accounts{"admin": "secret", "ops": "p:a:ss"}},
+		{name: "trailing comma", value: "admin:secret,", want: Accounts{"admin": "secret"}},
+		{name: "empty", value: "", want: Accounts{}},
+		{name: "missing password", value: "admin", wantErr: true},
+		{name: "empty password", value: "admin:", wantErr: true},
+		{name: "empty login", value: ":secret", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts := Accounts{"old": "account"}
+			err := accounts.Set(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(accounts) != len(tt.want) {
+				t.Fatalf("accounts = %v, want %v", accounts, tt.want)
+			}
+			for login, password := range tt.want {
+				if accounts[login] != password {
+					t.Errorf("password of %s = %q, want %q", login, accounts[login], password)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(cfg *Config)
+		wantErr string
+	}{
+		{name: "defaults", change: func(*Config) {}},
+		{name: "run address", change: func(cfg *Config) { cfg.RunAddress = "" },
+			wantErr: "run address is required"},
+		{name: "database URI", change: func(cfg *Config) { cfg.DatabaseDSN = "" },
+			wantErr: "database URI is required"},
+		{name: "relative accrual address", change: func(cfg *Config) { cfg.AccrualSystemAddress = "localhost:8000" },
+			wantErr: `accrual system address "localhost:8000" must be an absolute URL`},
+		{name: "zero duration", change: func(cfg *Config) { cfg.Accrual.PollInterval = 0 },
+			wantErr: "accrual.poll_interval must be positive, got 0s"},
+		{name: "negative drain delay", change: func(cfg *Config) { cfg.Server.DrainDelay = -time.Second },
+			wantErr: "server.drain_delay must not be negative"},
+		{name: "bcrypt cost", change: func(cfg *Config) { cfg.Auth.BcryptCost = 3 },
+			wantErr: "auth.bcrypt_cost must be between 4 and 31, got 3"},
+		{name: "worker count", change: func(cfg *Config) { cfg.Accrual.WorkerCount = 0 },
+			wantErr: "accrual.worker_count must be at least 1"},
+		{name: "worker bounds", change: func(cfg *Config) { cfg.Accrual.MinWorkers, cfg.Accrual.MaxWorkers = 5, 2 },
+			wantErr: "1 <= min_workers <= max_workers, got 5..2"},
+		{name: "batch size", change: func(cfg *Config) { cfg.Accrual.BatchSize = 0 },
+			wantErr: "accrual.batch_size must be at least 1"},
+		{name: "register attempts", change: func(cfg *Config) { cfg.Accrual.RegisterAttempts = 0 },
+			wantErr: "accrual.register_attempts must be at least 1"},
+		{name: "result batch size", change: func(cfg *Config) { cfg.Accrual.ResultBatchSize = 0 },
+			wantErr: "accrual.result_batch_size must be at least 1"},
+		{name: "breaker threshold", change: func(cfg *Config) { cfg.Accrual.BreakerThreshold = -1 },
+			wantErr: "accrual.breaker_threshold must not be negative"},
+		{name: "rate limit", change: func(cfg *Config) { cfg.Accrual.RateLimit = -1 },
+			wantErr: "accrual.rate_limit must not be negative"},
+		{name: "log level", change: func(cfg *Config) { cfg.Log.Level = "loud" },
+			wantErr: "log.level"},
+		{name: "component log level", change: func(cfg *Config) { cfg.Log.DBLevel = "loud" },
+			wantErr: "log.db_level"},
+		{name: "log format", change: func(cfg *Config) { cfg.Log.Format = "xml" },
+			wantErr: `log.format must be json or console, got "xml"`},
+		{name: "log sampling", change: func(cfg *Config) { cfg.Log.SamplingThereafter = -1 },
+			wantErr: "log sampling values must not be negative"},
+		{name: "order algorithm", change: func(cfg *Config) { cfg.Orders.Algorithm = "crc" },
+			wantErr: `orders: unknown order number algorithm "crc"`},
+		{name: "order length", change: func(cfg *Config) { cfg.Orders.MaxLength = 1 },
+			wantErr: "orders: invalid order number length bounds 2..1"},
+		{name: "review threshold", change: func(cfg *Config) { cfg.Withdraw.ReviewThreshold = -1 },
+			wantErr: "withdraw.review_threshold must not be negative"},
+		{name: "expiration months", change: func(cfg *Config) {
+			cfg.Expiration.Enabled, cfg.Expiration.Months = true, 0
+		}, wantErr: "expiration.months must be at least 1"},
+		{name: "expiration ignored while disabled", change: func(cfg *Config) { cfg.Expiration.Months = 0 }},
+		{name: "expiration interval", change: func(cfg *Config) {
+			cfg.Expiration.Enabled, cfg.Expiration.CheckInterval = true, 0
+		}, wantErr: "expiration.check_interval must be positive"},
+		{name: "expiration warn window", change: func(cfg *Config) {
+			cfg.Expiration.Enabled, cfg.Expiration.WarnWindow = true, -time.Hour
+		}, wantErr: "expiration.warn_window must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.DatabaseDSN = testDSN
+			tt.change(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateJoinsErrors(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.RunAddress = ""
+	cfg.Accrual.BatchSize = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted an invalid config")
+	}
+	for _, want := range []string{"run address is required", "database URI is required", "accrual.batch_size"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error = %v, want it to contain %q", err, want)
+		}
+	}
+}
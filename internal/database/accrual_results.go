@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+// UpdateOrdersAccrual applies a batch of accrual results in one transaction with the same rules as
+// ApplyAccrualCallback. Results for unknown orders and illegal transitions are skipped and returned in
+// rejected, keyed by order number; the error is set only when nothing was stored.
+func (db *DB) UpdateOrdersAccrual(
+	ctx context.Context,
+	orders []models.Order,
+	expiresAt *time.Time,
+) (rejected map[string]error, err error) {
+	numbers := make([]string, 0, len(orders))
+	for _, order := range orders {
+		numbers = append(numbers, order.Order)
+	}
+
+	err = db.inTx(ctx, func(tx pgx.Tx) error {
+		rejected = make(map[string]error)
+
+		// Rows are locked in a fixed order so that concurrent batches cannot deadlock.
+		const selectStatuses = `SELECT number, status FROM users_orders WHERE number = ANY($1) ORDER BY number FOR UPDATE;`
+		rows, err := tx.Query(ctx, selectStatuses, numbers)
+		if err != nil {
+			return fmt.Errorf("failed to get order statuses: %w", err)
+		}
+		current := make(map[string]models.OrderStatus, len(orders))
+		var number string
+		var status models.OrderStatus
+		_, err = pgx.ForEachRow(rows, []any{&number, &status}, func() error {
+			current[number] = status
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get order statuses: %w", err)
+		}
+
+		var updated, oldStatuses, newStatuses []string
+		var accruals []*float64
+		for _, order := range orders {
+			old, ok := current[order.Order]
+			switch {
+			case !ok:
+				rejected[order.Order] = myErrors.ErrOrderNotFound
+				continue
+			case !old.CanTransitionTo(order.Status):
+				rejected[order.Order] = fmt.Errorf("%w: %s -> %s", myErrors.ErrIllegalTransition, old, order.Status)
+				continue
+			case old == order.Status:
+				continue
+			}
+
+			var accrual *float64
+			if order.Status == models.OrderProcessed {
+				accrual = &order.Accrual
+			}
+			updated = append(updated, order.Order)
+			oldStatuses = append(oldStatuses, string(old))
+			newStatuses = append(newStatuses, string(order.Status))
+			accruals = append(accruals, accrual)
+		}
+		if len(updated) == 0 {
+			return nil
+		}
+
+		const updateOrders = `
+		UPDATE users_orders AS o SET status = v.status, accrual = v.accrual
+		FROM unnest($1::text[], $2::text[], $3::numeric[]) AS v(number, status, accrual)
+		WHERE o.number = v.number;`
+		if _, err := tx.Exec(ctx, updateOrders, updated, newStatuses, accruals); err != nil {
+			return fmt.Errorf("failed to update orders: %w", err)
+		}
+
+		const insertHistory = `
+		INSERT INTO order_status_history (order_number, old_status, new_status, accrual, source, changed_at)
+		SELECT number, old_status, new_status, accrual, $5, $6
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::numeric[]) AS v(number, old_status, new_status, accrual);`
+		_, err = tx.Exec(ctx, insertHistory, updated, oldStatuses, newStatuses, accruals,
+			models.HistorySourceAccrual, time.Now().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to insert status history: %w", err)
+		}
+
+		if expiresAt == nil {
+			return nil
+		}
+		return db.insertLots(ctx, tx, updated, time.Now(), *expiresAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rejected, nil
+}
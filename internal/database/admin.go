@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+func (db *DB) GetUserByLogin(ctx context.Context, login string) (models.UserInfo, error) {
+	user := models.UserInfo{Login: login}
+	const selectUser = `SELECT user_id, locked FROM users WHERE login = $1;`
+	if err := db.pool.QueryRow(ctx, selectUser, login).Scan(&user.UserID, &user.Locked); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, myErrors.ErrUserNotFound
+		}
+		return user, fmt.Errorf("failed to get user by login: %w", err)
+	}
+	return user, nil
+}
+
+func (db *DB) IsUserLocked(ctx context.Context, userID string) (bool, error) {
+	var locked bool
+	const selectLocked = `SELECT locked FROM users WHERE user_id = $1;`
+	if err := db.pool.QueryRow(ctx, selectLocked, userID).Scan(&locked); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, myErrors.ErrUserNotFound
+		}
+		return false, fmt.Errorf("failed to check user lock: %w", err)
+	}
+	return locked, nil
+}
+
+func (db *DB) SetUserLocked(ctx context.Context, login string, locked bool, entry models.AuditEntry) error {
+	const updateLocked = `UPDATE users SET locked = $1 WHERE login = $2;`
+	return db.execAudited(ctx, entry, myErrors.ErrUserNotFound, updateLocked, locked, login)
+}
+
+// SetOrderStatus forces the order to status. It refuses orders whose accrual was clawed back: the
+// clawback and the closed lot stay in the ledger, so a new accrual of the order would net to zero.
+func (db *DB) SetOrderStatus(
+	ctx context.Context,
+	number string,
+	status models.OrderStatus,
+	entry models.AuditEntry,
+) error {
+	return db.inTx(ctx, func(tx pgx.Tx) error {
+		var clawedBack bool
+		const selectOrder = `
+		SELECT EXISTS (SELECT 1 FROM users_adjustments WHERE reason = $2 AND reference = $1)
+		FROM users_orders WHERE number = $1 FOR UPDATE;`
+		if err := tx.QueryRow(ctx, selectOrder, number, models.ReasonAccrualClawback).Scan(&clawedBack); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return myErrors.ErrOrderNotFound
+			}
+			return fmt.Errorf("failed to get order: %w", err)
+		}
+		if clawedBack {
+			return myErrors.ErrOrderClawedBack
+		}
+
+		const updateStatus = `
+		WITH old AS (
+			SELECT number, status FROM users_orders WHERE number = $2
+		), updated AS (
+			UPDATE users_orders SET status = $1 FROM old WHERE users_orders.number = old.number
+			RETURNING users_orders.number, old.status AS old_status, users_orders.accrual
+		)` + insertAdminHistory
+		_, err := tx.Exec(ctx, updateStatus,
+			status, number, models.HistorySourceAdmin, time.Now().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to set order status: %w", err)
+		}
+		return db.insertAudit(ctx, tx, entry)
+	})
+}
+
+// InvalidateOrder marks the order INVALID. The accrual stays in the ledger and is reversed by an
+// ACCRUAL_CLAWBACK adjustment in the same transaction, unless the order was already clawed back.
+func (db *DB) InvalidateOrder(ctx context.Context, number string, entry models.AuditEntry) error {
+	return db.inTx(ctx, func(tx pgx.Tx) error {
+		err := db.clawbackOrder(ctx, tx, number, entry.Operator)
+		if err != nil && !errors.Is(err, myErrors.ErrNothingToAdjust) && !errors.Is(err, myErrors.ErrAlreadyAdjusted) {
+			return err
+		}
+		if err := db.invalidateOrder(ctx, tx, number); err != nil {
+			return err
+		}
+		return db.insertAudit(ctx, tx, entry)
+	})
+}
+
+func (db *DB) invalidateOrder(ctx context.Context, tx pgx.Tx, number string) error {
+	const updateInvalid = `
+	WITH old AS (
+		SELECT number, status FROM users_orders WHERE number = $2 AND status <> $1 FOR UPDATE
+	), updated AS (
+		UPDATE users_orders SET status = $1 FROM old WHERE users_orders.number = old.number
+		RETURNING users_orders.number, old.status AS old_status, users_orders.accrual
+	)` + insertAdminHistory
+	_, err := tx.Exec(ctx, updateInvalid,
+		models.OrderInvalid, number, models.HistorySourceAdmin, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to invalidate order: %w", err)
+	}
+	return nil
+}
+
+// insertAdminHistory completes the admin status updates above: it records the change of the updated
+// order, so the affected row count still tells whether the order exists.
+const insertAdminHistory = `
+	INSERT INTO order_status_history (order_number, old_status, new_status, accrual, source, changed_at)
+	SELECT number, old_status, $1, accrual, $3, $4 FROM updated;`
+
+// execAudited runs a single-row update and records it in the audit log in one transaction.
+func (db *DB) execAudited(
+	ctx context.Context,
+	entry models.AuditEntry,
+	errNotFound error,
+	sql string,
+	args ...any,
+) error {
+	return db.inTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, sql, args...)
+		if err != nil {
+			return fmt.Errorf("failed to run %s: %w", entry.Action, err)
+		}
+		if tag.RowsAffected() == 0 {
+			return errNotFound
+		}
+		return db.insertAudit(ctx, tx, entry)
+	})
+}
+
+type executor interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func (db *DB) Audit(ctx context.Context, entry models.AuditEntry) error {
+	return db.insertAudit(ctx, db.pool, entry)
+}
+
+func (db *DB) insertAudit(ctx context.Context, ex executor, entry models.AuditEntry) error {
+	const insertAudit = `
+	INSERT INTO admin_audit_log (operator, action, target, details, created_at) VALUES ($1, $2, $3, $4, $5);`
+	_, err := ex.Exec(ctx, insertAudit,
+		entry.Operator, entry.Action, entry.Target, entry.Details, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+func TestSetOrderStatus(t *testing.T) {
+	const (
+		userID = "user-1"
+		admin  = "admin"
+	)
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		number     string
+		clawback   bool
+		wantErr    error
+		wantStatus models.OrderStatus
+	}{
+		{name: "processed order goes back to NEW", number: "o1", wantStatus: models.OrderNew},
+		{name: "clawed back order is refused", number: "o1", clawback: true,
+			wantErr: myErrors.ErrOrderClawedBack, wantStatus: models.OrderInvalid},
+		{name: "unknown order", number: "o2", wantErr: myErrors.ErrOrderNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+
+			mustExec(t, db, `INSERT INTO users (user_id, login, pswd_hash) VALUES ($1, 'alice', '');`, userID)
+			mustExec(t, db, `
+			INSERT INTO users_orders (number, status, accrual, user_id, uploaded_at)
+			VALUES ('o1', 'PROCESSED', 100, $1, $2);`, userID, start.Format(time.RFC3339))
+			if tt.clawback {
+				entry := models.AuditEntry{Operator: admin, Action: "clawback_order", Target: "o1"}
+				if err := db.ClawbackOrder(ctx, "o1", entry); err != nil {
+					t.Fatalf("ClawbackOrder: %v", err)
+				}
+			}
+
+			entry := models.AuditEntry{Operator: admin, Action: "reset_order", Target: tt.number}
+			err := db.SetOrderStatus(ctx, tt.number, models.OrderNew, entry)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetOrderStatus error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantStatus == "" {
+				return
+			}
+
+			var status models.OrderStatus
+			const selectStatus = `SELECT status FROM users_orders WHERE number = $1;`
+			if err := db.pool.QueryRow(ctx, selectStatus, tt.number).Scan(&status); err != nil {
+				t.Fatalf("failed to get order: %v", err)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -17,6 +18,7 @@ import (
 	"go.uber.org/zap"
 
 	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+	"github.com/tiunovvv/gophermart/internal/metrics"
 	"github.com/tiunovvv/gophermart/internal/models"
 )
 
@@ -25,7 +27,7 @@ type DB struct {
 	log  *zap.SugaredLogger
 }
 
-func NewDB(ctx context.Context, databaseURI string, log *zap.SugaredLogger) (*DB, error) {
+func NewDB(ctx context.Context, databaseURI string, log *zap.SugaredLogger, m *metrics.Metrics) (*DB, error) {
 	poolCfg, err := pgxpool.ParseConfig(databaseURI)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse the DSN: %w", err)
@@ -46,6 +48,7 @@ func NewDB(ctx context.Context, databaseURI string, log *zap.SugaredLogger) (*DB
 	}
 
 	database := &DB{pool: pool, log: log}
+	m.MustRegister(newDBCollector(database))
 	return database, nil
 }
 
@@ -70,6 +73,57 @@ func runMigrations(databaseURI string) error {
 	return nil
 }
 
+func latestMigration() (uint, error) {
+	d, err := iofs.New(migrationsDir, "migrations")
+	if err != nil {
+		return 0, fmt.Errorf("failed to return an iofs driver: %w", err)
+	}
+
+	version, err := d.First()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get first migration: %w", err)
+	}
+	for {
+		next, err := d.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to get next migration: %w", err)
+		}
+		version = next
+	}
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	if err := db.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to ping DB: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) CheckMigrations(ctx context.Context) error {
+	latest, err := latestMigration()
+	if err != nil {
+		return err
+	}
+
+	var version int64
+	var dirty bool
+	const selectVersion = `SELECT version, dirty FROM schema_migrations LIMIT 1;`
+	if err := db.pool.QueryRow(ctx, selectVersion).Scan(&version, &dirty); err != nil {
+		return fmt.Errorf("failed to get migration version: %w", err)
+	}
+
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version < int64(latest) {
+		return fmt.Errorf("migration version %d is behind %d", version, latest)
+	}
+	return nil
+}
+
 func (db *DB) Close() {
 	db.pool.Close()
 }
@@ -98,7 +152,9 @@ func (db *DB) GetUserID(ctx context.Context, login string) (string, string, erro
 	return userID, hash, nil
 }
 
-func (db *DB) SaveOrder(ctx context.Context, userID string, number string) error {
+// SaveOrder stores a new order of the user. An order uploaded with goods keeps them and waits for
+// registration in the accrual system before it is polled.
+func (db *DB) SaveOrder(ctx context.Context, userID string, number string, goods []models.Good) error {
 	tx, err := db.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -128,25 +184,105 @@ func (db *DB) SaveOrder(ctx context.Context, userID string, number string) error
 
 	currentTime := time.Now()
 	rfc3339String := currentTime.Format(time.RFC3339)
+	var registration, registrationNextAt *string
+	if goods != nil {
+		pending := models.RegistrationPending
+		registration, registrationNextAt = &pending, &rfc3339String
+	}
 	const insertOrder = `
-	INSERT INTO users_orders (number, status, user_id, uploaded_at) VALUES ($1, $2, $3, $4) RETURNING number`
-	err = tx.QueryRow(ctx, insertOrder, number, `NEW`, userID, rfc3339String).Scan(&numberDB)
+	INSERT INTO users_orders (number, status, user_id, uploaded_at, registration, registration_next_at)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING number`
+	err = tx.QueryRow(ctx, insertOrder, number, models.OrderNew, userID, rfc3339String,
+		registration, registrationNextAt).Scan(&numberDB)
 	if err != nil {
 		return fmt.Errorf("failed to insert new order: %w", err)
 	}
 
+	if err := db.insertGoods(ctx, tx, number, goods); err != nil {
+		return err
+	}
+
+	if err := db.insertHistory(ctx, tx, number, "", models.OrderNew, nil, models.HistorySourceUpload); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 	return nil
 }
 
-func (db *DB) GetNewOrders(ctx context.Context) ([]models.OrderWithTime, error) {
-	const select100NewOrders = `
-	SELECT number, status, accrual, uploaded_at 
- 	FROM users_orders WHERE status = 'NEW' OR status = 'PROCESSING' 
-	ORDER BY uploaded_at ASC LIMIT 100;`
-	rows, err := db.pool.Query(ctx, select100NewOrders)
+// SaveOrders inserts new orders of the user with one multi-row insert and returns, for each number that
+// was already uploaded, the user who owns it.
+func (db *DB) SaveOrders(ctx context.Context, userID string, numbers []string) (map[string]string, error) {
+	owners := make(map[string]string)
+	err := db.inTx(ctx, func(tx pgx.Tx) error {
+		const insertOrders = `
+		INSERT INTO users_orders (number, status, user_id, uploaded_at)
+		SELECT number, 'NEW', $2, $3 FROM unnest($1::text[]) AS number
+		ON CONFLICT (number) DO NOTHING RETURNING number;`
+		rows, err := tx.Query(ctx, insertOrders, numbers, userID, time.Now().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to insert orders: %w", err)
+		}
+		inserted, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return fmt.Errorf("failed to insert orders: %w", err)
+		}
+
+		const insertHistory = `
+		INSERT INTO order_status_history (order_number, new_status, source, changed_at)
+		SELECT number, 'NEW', $2, $3 FROM unnest($1::text[]) AS number;`
+		_, err = tx.Exec(ctx, insertHistory, inserted, models.HistorySourceUpload, time.Now().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to insert status history: %w", err)
+		}
+		if len(inserted) == len(numbers) {
+			return nil
+		}
+
+		const selectOwners = `SELECT number, user_id FROM users_orders WHERE number = ANY($1) AND NOT number = ANY($2);`
+		rows, err = tx.Query(ctx, selectOwners, numbers, inserted)
+		if err != nil {
+			return fmt.Errorf("failed to select order owners: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var number, owner string
+			if err := rows.Scan(&number, &owner); err != nil {
+				return fmt.Errorf("failed to scan order owner: %w", err)
+			}
+			owners[number] = owner
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read order owners: %w", err)
+		}
+		return nil
+	})
+	return owners, err
+}
+
+// GetNewOrders returns up to limit NEW and PROCESSING orders not heard of since silentSince, i.e.
+// uploaded and last called back before it, skipping the excluded numbers. Orders are taken round-robin
+// across users, so one user with many orders does not delay the others, and within a round NEW orders
+// come before PROCESSING ones.
+func (db *DB) GetNewOrders(
+	ctx context.Context,
+	limit int,
+	exclude []string,
+	silentSince time.Time,
+) ([]models.OrderWithTime, error) {
+	const selectNewOrders = `
+	SELECT number, status, accrual, uploaded_at FROM (
+		SELECT number, status, COALESCE(accrual, 0) AS accrual, uploaded_at,
+			ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY status = 'NEW' DESC, uploaded_at) AS turn
+		FROM users_orders
+		WHERE status IN ('NEW', 'PROCESSING') AND NOT number = ANY($2)
+			AND COALESCE(last_callback_at, uploaded_at)::timestamptz < $3
+			AND (registration IS NULL OR registration = 'REGISTERED')
+	) due
+	ORDER BY turn, status = 'NEW' DESC, uploaded_at LIMIT $1;`
+	rows, err := db.pool.Query(ctx, selectNewOrders, limit, exclude, silentSince)
 	if err != nil {
 		return nil, fmt.Errorf("failed to select new orders: %w", err)
 	}
@@ -155,9 +291,24 @@ func (db *DB) GetNewOrders(ctx context.Context) ([]models.OrderWithTime, error)
 	return db.ScanOrders(rows)
 }
 
+// CountDueOrders returns the number of orders not heard of since silentSince that wait for a final
+// status from the accrual system.
+func (db *DB) CountDueOrders(ctx context.Context, silentSince time.Time) (int64, error) {
+	var count int64
+	const countDue = `
+	SELECT COUNT(*) FROM users_orders
+	WHERE status IN ('NEW', 'PROCESSING') AND COALESCE(last_callback_at, uploaded_at)::timestamptz < $1
+		AND (registration IS NULL OR registration = 'REGISTERED');`
+	if err := db.pool.QueryRow(ctx, countDue, silentSince).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count due orders: %w", err)
+	}
+	return count, nil
+}
+
 func (db *DB) GetOrdersForUser(ctx context.Context, userID string) ([]models.OrderWithTime, error) {
 	const selectOrdersForUser = `
-	SELECT number, status, accrual, uploaded_at FROM users_orders WHERE user_id = $1 ORDER BY uploaded_at ASC;`
+	SELECT number, status, COALESCE(accrual, 0), uploaded_at FROM users_orders WHERE user_id = $1
+	ORDER BY uploaded_at ASC;`
 	rows, err := db.pool.Query(ctx, selectOrdersForUser, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to select by user_id: %w", err)
@@ -185,7 +336,8 @@ func (db *DB) ScanOrders(rows pgx.Rows) ([]models.OrderWithTime, error) {
 	return orders, nil
 }
 
-func (db *DB) Getbalance(ctx context.Context, userID string) (models.Balance, error) {
+// Getbalance also reports points of lots that expire before expiringBefore.
+func (db *DB) Getbalance(ctx context.Context, userID string, expiringBefore time.Time) (models.Balance, error) {
 	var balance models.Balance
 
 	tx, err := db.pool.Begin(ctx)
@@ -204,6 +356,11 @@ func (db *DB) Getbalance(ctx context.Context, userID string) (models.Balance, er
 		return balance, fmt.Errorf("failed to get balance from db: %w", err)
 	}
 
+	balance.ExpiringSoon, err = db.getExpiringSoon(ctx, tx, userID, expiringBefore)
+	if err != nil {
+		return balance, err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return balance, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -220,19 +377,31 @@ func (db *DB) getBalanceDB(ctx context.Context, tx pgx.Tx, userID string) (model
 		}
 	}
 
-	const selectSumWithdrawn = `SELECT SUM(sum) FROM users_withdraw WHERE user_id = $1 GROUP BY user_id;`
-	if err := tx.QueryRow(ctx, selectSumWithdrawn, userID).Scan(&balance.Withdrawn); err != nil {
-		if !errors.Is(err, pgx.ErrNoRows) {
-			return balance, fmt.Errorf("failed to get withdraws sum: %w", err)
-		}
+	const selectSumWithdrawn = `
+	SELECT COALESCE(SUM(sum) FILTER (WHERE status = $2), 0), COALESCE(SUM(sum) FILTER (WHERE status = $3), 0)
+	FROM users_withdraw WHERE user_id = $1;`
+	if err := tx.QueryRow(ctx, selectSumWithdrawn, userID, models.WithdrawCompleted, models.WithdrawPending).
+		Scan(&balance.Withdrawn, &balance.Held); err != nil {
+		return balance, fmt.Errorf("failed to get withdraws sum: %w", err)
 	}
 
-	balance.Current -= balance.Withdrawn
+	var adjusted, reversed float64
+	const selectSumAdjustments = `
+	SELECT COALESCE(SUM(amount), 0), COALESCE(SUM(amount) FILTER (WHERE reason = $2), 0)
+	FROM users_adjustments WHERE user_id = $1;`
+	if err := tx.QueryRow(ctx, selectSumAdjustments, userID, models.ReasonWithdrawalReversal).
+		Scan(&adjusted, &reversed); err != nil {
+		return balance, fmt.Errorf("failed to get adjustments sum: %w", err)
+	}
+
+	balance.Current += adjusted - balance.Withdrawn - balance.Held
+	balance.Withdrawn -= reversed
 
 	return balance, nil
 }
 
-func (db *DB) SaveWithdraw(ctx context.Context, userID string, withdraw models.Withdraw) error {
+// SaveWithdraw debits the user. A PENDING withdrawal holds the sum until it is approved or rejected.
+func (db *DB) SaveWithdraw(ctx context.Context, userID string, withdraw models.Withdraw, status string) error {
 	tx, err := db.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -244,6 +413,15 @@ func (db *DB) SaveWithdraw(ctx context.Context, userID string, withdraw models.W
 		}
 	}()
 
+	// Concurrent withdrawals of the user wait here, so each one sees the balance left by the previous.
+	const lockUser = `SELECT user_id FROM users WHERE user_id = $1 FOR UPDATE;`
+	if err := tx.QueryRow(ctx, lockUser, userID).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return myErrors.ErrUserNotFound
+		}
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
 	balance, err := db.getBalanceDB(ctx, tx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to get balance from db: %w", err)
@@ -268,13 +446,18 @@ func (db *DB) SaveWithdraw(ctx context.Context, userID string, withdraw models.W
 	currentTime := time.Now()
 	rfc3339String := currentTime.Format(time.RFC3339)
 	const insertWithdraw = `
-	INSERT INTO users_withdraw (number, user_id, sum, processed_at) VALUES ($1, $2, $3, $4) RETURNING number;`
+	INSERT INTO users_withdraw (number, user_id, sum, processed_at, status) VALUES ($1, $2, $3, $4, $5)
+	RETURNING number;`
 	var numberDB string
 	if err := tx.QueryRow(
-		ctx, insertWithdraw, withdraw.Order, userID, withdraw.Sum, rfc3339String).Scan(&numberDB); err != nil {
+		ctx, insertWithdraw, withdraw.Order, userID, withdraw.Sum, rfc3339String, status).Scan(&numberDB); err != nil {
 		return fmt.Errorf("failed to insert new user: %w", err)
 	}
 
+	if err := db.consumeLots(ctx, tx, userID, withdraw.Order, withdraw.Sum); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -284,7 +467,7 @@ func (db *DB) SaveWithdraw(ctx context.Context, userID string, withdraw models.W
 
 func (db *DB) GetWindrawalsForUser(ctx context.Context, userID string) ([]models.Withdrawals, error) {
 	const selectWindrawalsForUser = `
-	SELECT number, sum, processed_at FROM users_withdraw WHERE user_id = $1 ORDER BY processed_at ASC;`
+	SELECT number, sum, processed_at, status FROM users_withdraw WHERE user_id = $1 ORDER BY processed_at ASC;`
 
 	rows, err := db.pool.Query(ctx, selectWindrawalsForUser, userID)
 	if err != nil {
@@ -294,16 +477,17 @@ func (db *DB) GetWindrawalsForUser(ctx context.Context, userID string) ([]models
 
 	var windrawals []models.Withdrawals
 	for rows.Next() {
-		var order, timeDB string
+		var order, timeDB, status string
 		var sum float64
 
-		err := rows.Scan(&order, &sum, &timeDB)
+		err := rows.Scan(&order, &sum, &timeDB, &status)
 		if err != nil {
 			db.log.Errorf("failed to get rows from users_withdraw by user_id: %w", err)
 		}
 		var withdraw models.Withdrawals
 		withdraw.Order = order
 		withdraw.Sum = sum
+		withdraw.Status = status
 		withdraw.ProcessedAt, err = time.Parse(time.RFC3339, timeDB)
 		if err != nil {
 			db.log.Errorf("failed to parse time: %w", err)
@@ -314,12 +498,51 @@ func (db *DB) GetWindrawalsForUser(ctx context.Context, userID string) ([]models
 	return windrawals, nil
 }
 
-func (db *DB) UpdateOrderAccrual(ctx context.Context, order models.Order) error {
-	const updateSchemaDeletedFlag = `UPDATE users_orders SET accrual = $1, status = $2 WHERE number = $3;`
+// ApplyAccrualCallback moves the order to order.Status pushed by the accrual system if the transition
+// is legal and stores the accrual of a processed order. Every accepted callback is remembered in
+// last_callback_at, which keeps the order out of polling. When expiresAt is set, a processed order also
+// opens an expiring lot.
+func (db *DB) ApplyAccrualCallback(ctx context.Context, order models.Order, expiresAt *time.Time) error {
+	return db.inTx(ctx, func(tx pgx.Tx) error {
+		var current models.OrderStatus
+		const selectStatus = `SELECT status FROM users_orders WHERE number = $1 FOR UPDATE;`
+		if err := tx.QueryRow(ctx, selectStatus, order.Order).Scan(&current); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return myErrors.ErrOrderNotFound
+			}
+			return fmt.Errorf("failed to get status of order=%s: %w", order.Order, err)
+		}
+		if !current.CanTransitionTo(order.Status) {
+			return fmt.Errorf("%w: %s -> %s", myErrors.ErrIllegalTransition, current, order.Status)
+		}
 
-	_, err := db.pool.Exec(ctx, updateSchemaDeletedFlag, order.Accrual, order.Status, order.Order)
-	if err != nil {
-		return fmt.Errorf("failed to update order=%s: %w", order.Order, err)
-	}
-	return nil
+		const updateCallback = `UPDATE users_orders SET last_callback_at = $2 WHERE number = $1;`
+		if _, err := tx.Exec(ctx, updateCallback, order.Order, time.Now().Format(time.RFC3339)); err != nil {
+			return fmt.Errorf("failed to record callback of order=%s: %w", order.Order, err)
+		}
+		if current == order.Status {
+			return nil
+		}
+
+		var accrual *float64
+		if order.Status == models.OrderProcessed {
+			accrual = &order.Accrual
+		}
+		const updateSchemaDeletedFlag = `UPDATE users_orders SET accrual = $1, status = $2 WHERE number = $3;`
+
+		_, err := tx.Exec(ctx, updateSchemaDeletedFlag, accrual, order.Status, order.Order)
+		if err != nil {
+			return fmt.Errorf("failed to update order=%s: %w", order.Order, err)
+		}
+
+		err = db.insertHistory(ctx, tx, order.Order, current, order.Status, accrual, models.HistorySourceCallback)
+		if err != nil {
+			return err
+		}
+
+		if expiresAt == nil {
+			return nil
+		}
+		return db.insertLot(ctx, tx, order.Order, time.Now(), *expiresAt)
+	})
 }
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+// insertHistory records a status change of an order; oldStatus is empty for a new order.
+func (db *DB) insertHistory(
+	ctx context.Context,
+	ex executor,
+	number string,
+	oldStatus models.OrderStatus,
+	newStatus models.OrderStatus,
+	accrual *float64,
+	source string,
+) error {
+	const insertHistory = `
+	INSERT INTO order_status_history (order_number, old_status, new_status, accrual, source, changed_at)
+	VALUES ($1, $2, $3, $4, $5, $6);`
+	_, err := ex.Exec(ctx, insertHistory, number, oldStatus, newStatus, accrual, source,
+		time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert status history of order=%s: %w", number, err)
+	}
+	return nil
+}
+
+// GetOrderForUser returns an order of the user together with its status timeline. Orders of other
+// users are reported as not found.
+func (db *DB) GetOrderForUser(ctx context.Context, userID string, number string) (models.OrderDetails, error) {
+	var details models.OrderDetails
+	var timeDB string
+	const selectOrder = `
+	SELECT number, status, COALESCE(accrual, 0), uploaded_at, COALESCE(registration, '') FROM users_orders
+	WHERE number = $1 AND user_id = $2;`
+	if err := db.pool.QueryRow(ctx, selectOrder, number, userID).
+		Scan(&details.Number, &details.Status, &details.Accrual, &timeDB, &details.Registration); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return details, myErrors.ErrOrderNotFound
+		}
+		return details, fmt.Errorf("failed to get order: %w", err)
+	}
+	var err error
+	if details.UploadedAt, err = time.Parse(time.RFC3339, timeDB); err != nil {
+		return details, fmt.Errorf("failed to parse upload time: %w", err)
+	}
+
+	const selectHistory = `
+	SELECT old_status, new_status, accrual, source, changed_at::timestamptz FROM order_status_history
+	WHERE order_number = $1 ORDER BY id;`
+	rows, err := db.pool.Query(ctx, selectHistory, number)
+	if err != nil {
+		return details, fmt.Errorf("failed to select status history: %w", err)
+	}
+	defer rows.Close()
+
+	details.History = []models.OrderStatusChange{}
+	for rows.Next() {
+		var change models.OrderStatusChange
+		if err := rows.Scan(&change.OldStatus, &change.Status, &change.Accrual, &change.Source,
+			&change.ChangedAt); err != nil {
+			return details, fmt.Errorf("failed to scan status change: %w", err)
+		}
+		details.History = append(details.History, change)
+	}
+	if err := rows.Err(); err != nil {
+		return details, fmt.Errorf("failed to read status history: %w", err)
+	}
+	return details, nil
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+// ReserveIdempotencyKey claims key for the user until lockedUntil. It returns nil when the key is new
+// or its previous claim ran out without a stored response, and the stored response when it was
+// already used for the same request. Keys created before expiredBefore are forgotten.
+func (db *DB) ReserveIdempotencyKey(
+	ctx context.Context,
+	userID string,
+	key string,
+	requestHash string,
+	lockedUntil time.Time,
+	expiredBefore time.Time,
+) (*models.IdempotentResponse, error) {
+	var response *models.IdempotentResponse
+	err := db.inTx(ctx, func(tx pgx.Tx) error {
+		const deleteExpired = `
+		DELETE FROM idempotency_keys WHERE user_id = $1 AND created_at::timestamptz < $2::timestamptz;`
+		if _, err := tx.Exec(ctx, deleteExpired, userID, expiredBefore); err != nil {
+			return fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+		}
+
+		const insertKey = `
+		INSERT INTO idempotency_keys (user_id, key, request_hash, created_at, locked_until) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, key) DO NOTHING;`
+		tag, err := tx.Exec(ctx, insertKey, userID, key, requestHash,
+			time.Now().Format(time.RFC3339), lockedUntil.Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to insert idempotency key: %w", err)
+		}
+		if tag.RowsAffected() == 1 {
+			return nil
+		}
+
+		var storedHash string
+		var stored models.IdempotentResponse
+		var leaseExpired bool
+		const selectKey = `
+		SELECT request_hash, status, content_type, body, locked_until::timestamptz <= now()
+		FROM idempotency_keys WHERE user_id = $1 AND key = $2 FOR UPDATE;`
+		if err := tx.QueryRow(ctx, selectKey, userID, key).
+			Scan(&storedHash, &stored.Status, &stored.ContentType, &stored.Body, &leaseExpired); err != nil {
+			return fmt.Errorf("failed to get idempotency key: %w", err)
+		}
+		switch {
+		case storedHash != requestHash:
+			return myErrors.ErrIdempotencyKeyReused
+		case stored.Status != 0:
+			response = &stored
+			return nil
+		case !leaseExpired:
+			return myErrors.ErrIdempotencyInProgress
+		}
+
+		// The request that claimed the key died without storing a response: the retry takes it over.
+		const renewLease = `UPDATE idempotency_keys SET locked_until = $3 WHERE user_id = $1 AND key = $2;`
+		if _, err := tx.Exec(ctx, renewLease, userID, key, lockedUntil.Format(time.RFC3339)); err != nil {
+			return fmt.Errorf("failed to take over idempotency key: %w", err)
+		}
+		return nil
+	})
+	return response, err
+}
+
+func (db *DB) SaveIdempotentResponse(
+	ctx context.Context,
+	userID string,
+	key string,
+	response models.IdempotentResponse,
+) error {
+	const updateKey = `
+	UPDATE idempotency_keys SET status = $3, content_type = $4, body = $5 WHERE user_id = $1 AND key = $2;`
+	_, err := db.pool.Exec(ctx, updateKey, userID, key, response.Status, response.ContentType, response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a reserved key so that the request can be retried.
+func (db *DB) ReleaseIdempotencyKey(ctx context.Context, userID string, key string) error {
+	const deleteKey = `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2;`
+	if _, err := db.pool.Exec(ctx, deleteKey, userID, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	const (
+		userID = "user-1"
+		key    = "key-1"
+		hash   = "hash-1"
+	)
+	now := time.Now()
+	stored := models.IdempotentResponse{Status: 200, ContentType: "text/plain", Body: []byte("ok")}
+
+	tests := []struct {
+		name         string
+		firstLease   time.Time
+		saved        bool
+		retryHash    string
+		wantErr      error
+		wantResponse bool
+	}{
+		{name: "claimed key is in progress", firstLease: now.Add(time.Minute), retryHash: hash,
+			wantErr: myErrors.ErrIdempotencyInProgress},
+		{name: "expired claim is taken over", firstLease: now.Add(-time.Second), retryHash: hash},
+		{name: "stored response is replayed", firstLease: now.Add(time.Minute), saved: true, retryHash: hash,
+			wantResponse: true},
+		{name: "another request is rejected", firstLease: now.Add(-time.Second), retryHash: "hash-2",
+			wantErr: myErrors.ErrIdempotencyKeyReused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+			expiredBefore := now.Add(-24 * time.Hour)
+
+			if _, err := db.ReserveIdempotencyKey(ctx, userID, key, hash, tt.firstLease, expiredBefore); err != nil {
+				t.Fatalf("first ReserveIdempotencyKey: %v", err)
+			}
+			if tt.saved {
+				if err := db.SaveIdempotentResponse(ctx, userID, key, stored); err != nil {
+					t.Fatalf("SaveIdempotentResponse: %v", err)
+				}
+			}
+
+			response, err := db.ReserveIdempotencyKey(ctx, userID, key, tt.retryHash, now.Add(time.Minute), expiredBefore)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("retry error = %v, want %v", err, tt.wantErr)
+			}
+			if (response != nil) != tt.wantResponse {
+				t.Errorf("retry response = %v, want replay %v", response, tt.wantResponse)
+			}
+		})
+	}
+}
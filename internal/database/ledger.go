@@ -0,0 +1,279 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+// SaveAdjustment credits or debits the user with the given login. A debit never takes the balance below
+// zero, like a withdrawal it locks the user and returns ErrNoMoney when the balance is short.
+func (db *DB) SaveAdjustment(
+	ctx context.Context,
+	login string,
+	adjustment models.Adjustment,
+	entry models.AuditEntry,
+) error {
+	return db.inTx(ctx, func(tx pgx.Tx) error {
+		var userID string
+		const selectUserID = `SELECT user_id FROM users WHERE login = $1 FOR UPDATE;`
+		if err := tx.QueryRow(ctx, selectUserID, login).Scan(&userID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return myErrors.ErrUserNotFound
+			}
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		if adjustment.Amount < 0 {
+			balance, err := db.getBalanceDB(ctx, tx, userID)
+			if err != nil {
+				return fmt.Errorf("failed to get balance from db: %w", err)
+			}
+			if balance.Current < -adjustment.Amount {
+				return myErrors.ErrNoMoney
+			}
+		}
+
+		if err := db.insertAdjustment(ctx, tx, userID, adjustment, entry.Operator); err != nil {
+			return err
+		}
+		// A debit spends lots like a withdrawal does, otherwise they would expire points the user no
+		// longer has. Debits are never restored, so the consumption is not recorded.
+		if adjustment.Amount < 0 {
+			if err := db.consumeLots(ctx, tx, userID, "", -adjustment.Amount); err != nil {
+				return err
+			}
+		}
+		return db.insertAudit(ctx, tx, entry)
+	})
+}
+
+// RefundWithdraw returns the whole sum of a completed withdrawal to its owner. A withdrawal can be
+// refunded once.
+func (db *DB) RefundWithdraw(ctx context.Context, number string, entry models.AuditEntry) error {
+	return db.inTx(ctx, func(tx pgx.Tx) error {
+		var userID, status string
+		var sum float64
+		const selectWithdraw = `SELECT user_id, sum, status FROM users_withdraw WHERE number = $1;`
+		if err := tx.QueryRow(ctx, selectWithdraw, number).Scan(&userID, &sum, &status); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return myErrors.ErrWithdrawNotFound
+			}
+			return fmt.Errorf("failed to get withdraw: %w", err)
+		}
+		if status != models.WithdrawCompleted {
+			return myErrors.ErrNothingToAdjust
+		}
+
+		adjustment := models.Adjustment{Amount: sum, Reason: models.ReasonWithdrawalReversal, Reference: number}
+		if err := db.insertAdjustment(ctx, tx, userID, adjustment, entry.Operator); err != nil {
+			return err
+		}
+		if err := db.restoreLots(ctx, tx, number); err != nil {
+			return err
+		}
+		return db.insertAudit(ctx, tx, entry)
+	})
+}
+
+// ClawbackOrder debits the accrual of a processed order and marks it INVALID, the same reversal
+// InvalidateOrder applies. An order can be clawed back once.
+func (db *DB) ClawbackOrder(ctx context.Context, number string, entry models.AuditEntry) error {
+	return db.inTx(ctx, func(tx pgx.Tx) error {
+		if err := db.clawbackOrder(ctx, tx, number, entry.Operator); err != nil {
+			return err
+		}
+		if err := db.invalidateOrder(ctx, tx, number); err != nil {
+			return err
+		}
+		return db.insertAudit(ctx, tx, entry)
+	})
+}
+
+// clawbackOrder writes the ACCRUAL_CLAWBACK adjustment for the order and closes its lot. The check for
+// an earlier clawback is explicit so that callers can go on with the transaction on ErrAlreadyAdjusted.
+func (db *DB) clawbackOrder(ctx context.Context, tx pgx.Tx, number string, operator string) error {
+	var userID string
+	var accrual float64
+	var clawedBack bool
+	const selectOrder = `
+	SELECT user_id, COALESCE(accrual, 0),
+		EXISTS (SELECT 1 FROM users_adjustments WHERE reason = $2 AND reference = $1)
+	FROM users_orders WHERE number = $1 FOR UPDATE;`
+	if err := tx.QueryRow(ctx, selectOrder, number, models.ReasonAccrualClawback).
+		Scan(&userID, &accrual, &clawedBack); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return myErrors.ErrOrderNotFound
+		}
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+	if accrual <= 0 {
+		return myErrors.ErrNothingToAdjust
+	}
+	if clawedBack {
+		return myErrors.ErrAlreadyAdjusted
+	}
+
+	adjustment := models.Adjustment{Amount: -accrual, Reason: models.ReasonAccrualClawback, Reference: number}
+	if err := db.insertAdjustment(ctx, tx, userID, adjustment, operator); err != nil {
+		return err
+	}
+	return db.closeLot(ctx, tx, number)
+}
+
+// ResolveWithdraw moves a PENDING withdrawal to status. Rejected and canceled withdrawals release the
+// hold and give back the lots they consumed. A non-empty userID restricts the lookup to that user's
+// withdrawals; entry is written to the audit log when set.
+func (db *DB) ResolveWithdraw(
+	ctx context.Context,
+	number string,
+	userID string,
+	status string,
+	entry *models.AuditEntry,
+) error {
+	return db.inTx(ctx, func(tx pgx.Tx) error {
+		var ownerID, current string
+		const selectWithdraw = `SELECT user_id, status FROM users_withdraw WHERE number = $1 FOR UPDATE;`
+		if err := tx.QueryRow(ctx, selectWithdraw, number).Scan(&ownerID, &current); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return myErrors.ErrWithdrawNotFound
+			}
+			return fmt.Errorf("failed to get withdraw: %w", err)
+		}
+		if userID != "" && userID != ownerID {
+			return myErrors.ErrWithdrawNotFound
+		}
+		if current != models.WithdrawPending {
+			return myErrors.ErrWithdrawNotPending
+		}
+
+		const updateStatus = `UPDATE users_withdraw SET status = $2 WHERE number = $1;`
+		if _, err := tx.Exec(ctx, updateStatus, number, status); err != nil {
+			return fmt.Errorf("failed to update withdraw status: %w", err)
+		}
+		if status != models.WithdrawCompleted {
+			if err := db.restoreLots(ctx, tx, number); err != nil {
+				return err
+			}
+		}
+
+		if entry == nil {
+			return nil
+		}
+		return db.insertAudit(ctx, tx, *entry)
+	})
+}
+
+func (db *DB) insertAdjustment(
+	ctx context.Context,
+	tx pgx.Tx,
+	userID string,
+	adjustment models.Adjustment,
+	operator string,
+) error {
+	const insertAdjustment = `
+	INSERT INTO users_adjustments (user_id, amount, reason, reference, operator, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6);`
+	_, err := tx.Exec(ctx, insertAdjustment, userID, adjustment.Amount, adjustment.Reason,
+		adjustment.Reference, operator, time.Now().Format(time.RFC3339))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return myErrors.ErrAlreadyAdjusted
+		}
+		return fmt.Errorf("failed to insert adjustment: %w", err)
+	}
+	return nil
+}
+
+// ledgerCTE lists every balance change of user $1 with its signed amount. Status is the status of
+// the order or withdrawal, reason the reason of an adjustment. An accrual is dated by the order's move
+// to PROCESSED, orders processed before the status history was kept fall back to the upload time,
+// which is listed for accruals in uploaded_at. Transactions and statements are both read from it,
+// so they always agree.
+const ledgerCTE = `
+	WITH ledger AS (
+		SELECT 'ACCRUAL' AS type, o.number AS reference, '' AS reason, o.status, o.accrual AS amount,
+			COALESCE((
+				SELECT MIN(h.changed_at::timestamptz) FROM order_status_history h
+				WHERE h.order_number = o.number AND h.new_status = 'PROCESSED'
+			), o.uploaded_at::timestamptz) AS created_at,
+			0::bigint AS id, o.uploaded_at::timestamptz AS uploaded_at
+		FROM users_orders o WHERE o.user_id = $1 AND o.accrual > 0
+		UNION ALL
+		SELECT 'WITHDRAWAL', number, '', status, -sum, processed_at::timestamptz, 0, NULL
+		FROM users_withdraw WHERE user_id = $1 AND status IN ('PENDING', 'COMPLETED')
+		UNION ALL
+		SELECT 'ADJUSTMENT', reference, reason, '', amount, created_at::timestamptz, id, NULL
+		FROM users_adjustments WHERE user_id = $1
+	)`
+
+// GetTransactionsForUser returns the user's ledger in chronological order. The running balance is
+// computed over the whole history before the period and page are applied.
+func (db *DB) GetTransactionsForUser(
+	ctx context.Context,
+	userID string,
+	period models.Period,
+	page models.Page,
+) ([]models.Transaction, error) {
+	const selectTransactions = ledgerCTE + `, running AS (
+		SELECT type, reference, reason, amount, created_at, id,
+			SUM(amount) OVER (ORDER BY created_at, type, reference, id ROWS UNBOUNDED PRECEDING) AS balance
+		FROM ledger
+	)
+	SELECT type, reference, reason, amount, balance, created_at FROM running
+	WHERE ($2::timestamptz IS NULL OR created_at >= $2) AND ($3::timestamptz IS NULL OR created_at < $3)
+	ORDER BY created_at, type, reference, id
+	LIMIT $4 OFFSET $5;`
+
+	rows, err := db.pool.Query(ctx, selectTransactions, userID, period.From, period.To, page.Limit, page.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var transaction models.Transaction
+		if err := rows.Scan(&transaction.Type, &transaction.Reference, &transaction.Reason,
+			&transaction.Amount, &transaction.Balance, &transaction.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transactions: %w", err)
+	}
+
+	return transactions, nil
+}
+
+func (db *DB) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			db.log.Infof("failed to rollback: %v", err)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+func TestSaveAdjustment(t *testing.T) {
+	const (
+		userID = "user-1"
+		login  = "alice"
+	)
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		login       string
+		amount      float64
+		wantErr     error
+		wantBalance float64
+	}{
+		{name: "credit", login: login, amount: 50, wantBalance: 150},
+		{name: "debit within the balance", login: login, amount: -100, wantBalance: 0},
+		{name: "debit over the balance", login: login, amount: -100.01,
+			wantErr: myErrors.ErrNoMoney, wantBalance: 100},
+		{name: "unknown user", login: "bob", amount: 50, wantErr: myErrors.ErrUserNotFound, wantBalance: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+
+			mustExec(t, db, `INSERT INTO users (user_id, login, pswd_hash) VALUES ($1, $2, '');`, userID, login)
+			mustExec(t, db, `
+			INSERT INTO users_orders (number, status, accrual, user_id, uploaded_at)
+			VALUES ('o1', 'PROCESSED', 100, $1, $2);`, userID, start.Format(time.RFC3339))
+
+			adjustment := models.Adjustment{Amount: tt.amount, Reason: models.ReasonCorrection}
+			entry := models.AuditEntry{Operator: "admin", Action: "adjust_balance", Target: tt.login}
+			if err := db.SaveAdjustment(ctx, tt.login, adjustment, entry); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SaveAdjustment error = %v, want %v", err, tt.wantErr)
+			}
+
+			balance, err := db.Getbalance(ctx, userID, start)
+			if err != nil {
+				t.Fatalf("Getbalance: %v", err)
+			}
+			if balance.Current != tt.wantBalance {
+				t.Errorf("balance = %v, want %v", balance.Current, tt.wantBalance)
+			}
+		})
+	}
+}
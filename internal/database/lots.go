@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+// insertLot opens an expiring lot for the accrual of a processed order. It is a no-op for other orders
+// and for orders that already have a lot.
+func (db *DB) insertLot(ctx context.Context, tx pgx.Tx, number string, accruedAt time.Time, expiresAt time.Time) error {
+	if err := db.insertLots(ctx, tx, []string{number}, accruedAt, expiresAt); err != nil {
+		return fmt.Errorf("failed to insert lot for order=%s: %w", number, err)
+	}
+	return nil
+}
+
+// insertLots is insertLot for several orders at once.
+func (db *DB) insertLots(ctx context.Context, tx pgx.Tx, numbers []string, accruedAt time.Time, expiresAt time.Time) error {
+	const insertLots = `
+	INSERT INTO accrual_lots (user_id, order_number, amount, remaining, accrued_at, expires_at)
+	SELECT user_id, number, accrual, accrual, $2, $3 FROM users_orders
+	WHERE number = ANY($1) AND status = 'PROCESSED' AND accrual > 0
+	ON CONFLICT (order_number) DO NOTHING;`
+	_, err := tx.Exec(ctx, insertLots, numbers, accruedAt.Format(time.RFC3339), expiresAt.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert lots: %w", err)
+	}
+	return nil
+}
+
+// consumeLots spends the oldest lots of the user first. Points not covered by lots never expire,
+// so a withdrawal larger than the lots simply empties them. With an empty withdrawNumber the
+// consumption is not recorded and cannot be restored.
+func (db *DB) consumeLots(ctx context.Context, tx pgx.Tx, userID string, withdrawNumber string, sum float64) error {
+	const selectLots = `
+	SELECT id, remaining FROM accrual_lots WHERE user_id = $1 AND remaining > 0
+	ORDER BY expires_at::timestamptz, id FOR UPDATE;`
+	rows, err := tx.Query(ctx, selectLots, userID)
+	if err != nil {
+		return fmt.Errorf("failed to select lots: %w", err)
+	}
+
+	type consumption struct {
+		lotID  int64
+		amount float64
+	}
+	var consumptions []consumption
+	for rows.Next() && sum > 0 {
+		var c consumption
+		var remaining float64
+		if err := rows.Scan(&c.lotID, &remaining); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan lot: %w", err)
+		}
+		c.amount = min(remaining, sum)
+		sum -= c.amount
+		consumptions = append(consumptions, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read lots: %w", err)
+	}
+
+	const updateLot = `UPDATE accrual_lots SET remaining = remaining - $2 WHERE id = $1;`
+	const insertConsumption = `
+	INSERT INTO accrual_lot_consumptions (lot_id, withdraw_number, amount) VALUES ($1, $2, $3);`
+	for _, c := range consumptions {
+		if _, err := tx.Exec(ctx, updateLot, c.lotID, c.amount); err != nil {
+			return fmt.Errorf("failed to consume lot: %w", err)
+		}
+		if withdrawNumber == "" {
+			continue
+		}
+		if _, err := tx.Exec(ctx, insertConsumption, c.lotID, withdrawNumber, c.amount); err != nil {
+			return fmt.Errorf("failed to record lot consumption: %w", err)
+		}
+	}
+	return nil
+}
+
+// restoreLots gives back what a withdrawal took from lots. Lots that expired in the meantime are
+// expired again by the next run of ExpireLots.
+func (db *DB) restoreLots(ctx context.Context, tx pgx.Tx, withdrawNumber string) error {
+	const restoreLots = `
+	WITH consumed AS (
+		DELETE FROM accrual_lot_consumptions WHERE withdraw_number = $1 RETURNING lot_id, amount
+	)
+	UPDATE accrual_lots SET remaining = remaining + consumed.amount
+	FROM consumed WHERE accrual_lots.id = consumed.lot_id;`
+	if _, err := tx.Exec(ctx, restoreLots, withdrawNumber); err != nil {
+		return fmt.Errorf("failed to restore lots: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) closeLot(ctx context.Context, tx pgx.Tx, number string) error {
+	const closeLot = `UPDATE accrual_lots SET remaining = 0 WHERE order_number = $1;`
+	if _, err := tx.Exec(ctx, closeLot, number); err != nil {
+		return fmt.Errorf("failed to close lot: %w", err)
+	}
+	return nil
+}
+
+// ExpireLots zeroes every lot that expired by now and writes a matching EXPIRATION ledger entry.
+// The write-off never exceeds the user's balance: the lots due for a user are expired oldest first
+// and each takes at most what the previous ones left of the balance. It returns the number of expired lots.
+func (db *DB) ExpireLots(ctx context.Context, now time.Time) (int64, error) {
+	const expireLots = `
+	WITH due AS (
+		SELECT id, user_id, order_number, remaining, expires_at::timestamptz AS expires_at FROM accrual_lots
+		WHERE remaining > 0 AND expires_at::timestamptz <= $1::timestamptz
+		FOR UPDATE SKIP LOCKED
+	), balances AS (
+		SELECT u.user_id,
+			COALESCE((SELECT SUM(accrual) FROM users_orders o WHERE o.user_id = u.user_id), 0)
+			+ COALESCE((SELECT SUM(amount) FROM users_adjustments a WHERE a.user_id = u.user_id), 0)
+			- COALESCE((SELECT SUM(sum) FROM users_withdraw w
+				WHERE w.user_id = u.user_id AND w.status IN ('PENDING', 'COMPLETED')), 0) AS available
+		FROM (SELECT DISTINCT user_id FROM due) u
+	), capped AS (
+		SELECT due.id, due.user_id, due.order_number,
+			LEAST(due.remaining, GREATEST(balances.available - COALESCE(SUM(due.remaining) OVER (
+				PARTITION BY due.user_id ORDER BY due.expires_at, due.id
+				ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING), 0), 0)) AS amount
+		FROM due JOIN balances ON balances.user_id = due.user_id
+	), expired AS (
+		UPDATE accrual_lots SET remaining = 0 FROM capped WHERE accrual_lots.id = capped.id
+		RETURNING accrual_lots.id
+	), written_off AS (
+		INSERT INTO users_adjustments (user_id, amount, reason, reference, operator, created_at)
+		SELECT user_id, -amount, $2, order_number, $3, $4 FROM capped WHERE amount > 0
+	)
+	SELECT COUNT(*) FROM expired;`
+
+	var expired int64
+	rfc3339String := now.Format(time.RFC3339)
+	err := db.pool.QueryRow(ctx, expireLots, now, models.ReasonExpiration, models.OperatorSystem, rfc3339String).
+		Scan(&expired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire lots: %w", err)
+	}
+	return expired, nil
+}
+
+func (db *DB) getExpiringSoon(ctx context.Context, tx pgx.Tx, userID string, before time.Time) (float64, error) {
+	var expiring float64
+	const selectExpiring = `
+	SELECT COALESCE(SUM(remaining), 0) FROM accrual_lots
+	WHERE user_id = $1 AND remaining > 0 AND expires_at::timestamptz <= $2::timestamptz;`
+	if err := tx.QueryRow(ctx, selectExpiring, userID, before).Scan(&expiring); err != nil {
+		return 0, fmt.Errorf("failed to get expiring points: %w", err)
+	}
+	return expiring, nil
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/tiunovvv/gophermart/internal/config"
+	"github.com/tiunovvv/gophermart/internal/expiration"
+	"github.com/tiunovvv/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// expirerMart remembers how many lots the last expiration reported.
+type expirerMart struct {
+	db      *DB
+	expired int64
+}
+
+func (m *expirerMart) ExpirePoints(ctx context.Context, now time.Time) (int64, error) {
+	expired, err := m.db.ExpireLots(ctx, now)
+	m.expired = expired
+	return expired, err
+}
+
+func TestLots(t *testing.T) {
+	const (
+		userID = "user-1"
+		login  = "alice"
+		admin  = "admin"
+	)
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	withdraw := func(status string) func(context.Context, *testing.T, *DB) {
+		return func(ctx context.Context, t *testing.T, db *DB) {
+			t.Helper()
+			if err := db.SaveWithdraw(ctx, userID, models.Withdraw{Order: "w1", Sum: 120}, status); err != nil {
+				t.Fatalf("SaveWithdraw: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name          string
+		act           func(ctx context.Context, t *testing.T, db *DB)
+		advance       time.Duration
+		wantRemaining map[string]float64
+		wantBalance   float64
+		wantExpired   int64
+	}{
+		{
+			name:          "withdrawal spends the oldest lot first",
+			act:           withdraw(models.WithdrawCompleted),
+			wantRemaining: map[string]float64{"o1": 0, "o2": 30},
+			wantBalance:   30,
+		},
+		{
+			name:          "due lot expires",
+			advance:       day + time.Second,
+			wantRemaining: map[string]float64{"o1": 0, "o2": 50},
+			wantBalance:   50,
+			wantExpired:   1,
+		},
+		{
+			name: "rejected withdrawal restores lots",
+			act: func(ctx context.Context, t *testing.T, db *DB) {
+				withdraw(models.WithdrawPending)(ctx, t, db)
+				if err := db.ResolveWithdraw(ctx, "w1", userID, models.WithdrawRejected, nil); err != nil {
+					t.Fatalf("ResolveWithdraw: %v", err)
+				}
+			},
+			wantRemaining: map[string]float64{"o1": 100, "o2": 50},
+			wantBalance:   150,
+		},
+		{
+			name: "refunded withdrawal restores lots",
+			act: func(ctx context.Context, t *testing.T, db *DB) {
+				withdraw(models.WithdrawCompleted)(ctx, t, db)
+				entry := models.AuditEntry{Operator: admin, Action: "refund_withdraw", Target: "w1"}
+				if err := db.RefundWithdraw(ctx, "w1", entry); err != nil {
+					t.Fatalf("RefundWithdraw: %v", err)
+				}
+			},
+			wantRemaining: map[string]float64{"o1": 100, "o2": 50},
+			wantBalance:   150,
+		},
+		{
+			name: "negative correction spends lots before they expire",
+			act: func(ctx context.Context, t *testing.T, db *DB) {
+				adjustment := models.Adjustment{Amount: -120, Reason: models.ReasonCorrection}
+				entry := models.AuditEntry{Operator: admin, Action: "adjust_balance", Target: login}
+				if err := db.SaveAdjustment(ctx, login, adjustment, entry); err != nil {
+					t.Fatalf("SaveAdjustment: %v", err)
+				}
+			},
+			advance:       3 * day,
+			wantRemaining: map[string]float64{"o1": 0, "o2": 0},
+			wantBalance:   0,
+			wantExpired:   1,
+		},
+		{
+			name: "invalidated order closes its lot",
+			act: func(ctx context.Context, t *testing.T, db *DB) {
+				entry := models.AuditEntry{Operator: admin, Action: "invalidate_order", Target: "o1"}
+				if err := db.InvalidateOrder(ctx, "o1", entry); err != nil {
+					t.Fatalf("InvalidateOrder: %v", err)
+				}
+			},
+			advance:       3 * day,
+			wantRemaining: map[string]float64{"o1": 0, "o2": 0},
+			wantBalance:   0,
+			wantExpired:   1,
+		},
+		{
+			name: "expiry never exceeds the balance",
+			act: func(ctx context.Context, t *testing.T, db *DB) {
+				mustExec(t, db, `
+				INSERT INTO users_adjustments (user_id, amount, reason, operator, created_at)
+				VALUES ($1, -130, $2, $3, $4);`, userID, models.ReasonCorrection, admin, start.Format(time.RFC3339))
+			},
+			advance:       3 * day,
+			wantRemaining: map[string]float64{"o1": 0, "o2": 0},
+			wantBalance:   0,
+			wantExpired:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+			clock := &fakeClock{now: start}
+
+			mustExec(t, db, `INSERT INTO users (user_id, login, pswd_hash) VALUES ($1, $2, '');`, userID, login)
+			mustExec(t, db, `
+			INSERT INTO users_orders (number, status, accrual, user_id, uploaded_at)
+			VALUES ('o1', 'PROCESSED', 100, $1, $2), ('o2', 'PROCESSED', 50, $1, $2);`,
+				userID, start.Format(time.RFC3339))
+			err := db.inTx(ctx, func(tx pgx.Tx) error {
+				if err := db.insertLot(ctx, tx, "o1", start, start.Add(day)); err != nil {
+					return err
+				}
+				return db.insertLot(ctx, tx, "o2", start, start.Add(2*day))
+			})
+			if err != nil {
+				t.Fatalf("failed to insert lots: %v", err)
+			}
+
+			if tt.act != nil {
+				tt.act(ctx, t, db)
+			}
+			clock.now = clock.now.Add(tt.advance)
+			cfg := &config.Config{Expiration: config.ExpirationConfig{Enabled: true, CheckInterval: time.Hour}}
+			mart := &expirerMart{db: db}
+			expiration.NewExpirer(cfg, mart, zap.NewNop().Sugar(), clock).Run(ctx)
+			if mart.expired != tt.wantExpired {
+				t.Errorf("expired lots = %d, want %d", mart.expired, tt.wantExpired)
+			}
+
+			for number, want := range tt.wantRemaining {
+				var remaining float64
+				const selectRemaining = `SELECT remaining FROM accrual_lots WHERE order_number = $1;`
+				if err := db.pool.QueryRow(ctx, selectRemaining, number).Scan(&remaining); err != nil {
+					t.Fatalf("failed to get lot %s: %v", number, err)
+				}
+				if remaining != want {
+					t.Errorf("lot %s remaining = %v, want %v", number, remaining, want)
+				}
+			}
+
+			balance, err := db.Getbalance(ctx, userID, clock.now)
+			if err != nil {
+				t.Fatalf("Getbalance: %v", err)
+			}
+			if balance.Current != tt.wantBalance {
+				t.Errorf("balance = %v, want %v", balance.Current, tt.wantBalance)
+			}
+		})
+	}
+}
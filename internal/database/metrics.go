@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsQueryTimeout = 2 * time.Second
+	// metricsCacheTTL keeps scrapes from running the aggregate queries more often than this.
+	metricsCacheTTL = 15 * time.Second
+)
+
+// dbStats is the result of the aggregate queries, cached between scrapes.
+type dbStats struct {
+	fetchedAt      time.Time
+	ordersByStatus map[string]int64
+	accrued        float64
+	withdrawn      float64
+}
+
+type dbCollector struct {
+	db *DB
+
+	mu    sync.Mutex
+	stats *dbStats
+
+	acquiredConns   *prometheus.Desc
+	idleConns       *prometheus.Desc
+	totalConns      *prometheus.Desc
+	maxConns        *prometheus.Desc
+	acquireCount    *prometheus.Desc
+	acquireDuration *prometheus.Desc
+	emptyAcquire    *prometheus.Desc
+	ordersByStatus  *prometheus.Desc
+	pointsAccrued   *prometheus.Desc
+	pointsWithdrawn *prometheus.Desc
+}
+
+func newDBCollector(db *DB) *dbCollector {
+	const namespace = "gophermart"
+	return &dbCollector{
+		db:              db,
+		acquiredConns:   prometheus.NewDesc(namespace+"_db_pool_acquired_conns", "Currently acquired connections.", nil, nil),
+		idleConns:       prometheus.NewDesc(namespace+"_db_pool_idle_conns", "Currently idle connections.", nil, nil),
+		totalConns:      prometheus.NewDesc(namespace+"_db_pool_total_conns", "Total connections in the pool.", nil, nil),
+		maxConns:        prometheus.NewDesc(namespace+"_db_pool_max_conns", "Maximum size of the pool.", nil, nil),
+		acquireCount:    prometheus.NewDesc(namespace+"_db_pool_acquire_total", "Successful acquires from the pool.", nil, nil),
+		acquireDuration: prometheus.NewDesc(namespace+"_db_pool_acquire_seconds_total", "Time spent acquiring connections.", nil, nil),
+		emptyAcquire:    prometheus.NewDesc(namespace+"_db_pool_empty_acquire_total", "Acquires that had to wait for a connection.", nil, nil),
+		ordersByStatus:  prometheus.NewDesc(namespace+"_orders", "Orders by status.", []string{"status"}, nil),
+		pointsAccrued:   prometheus.NewDesc(namespace+"_points_accrued", "Points currently accrued to users by orders.", nil, nil),
+		pointsWithdrawn: prometheus.NewDesc(namespace+"_points_withdrawn_total", "Total points withdrawn by users.", nil, nil),
+	}
+}
+
+func (c *dbCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquire
+	ch <- c.ordersByStatus
+	ch <- c.pointsAccrued
+	ch <- c.pointsWithdrawn
+}
+
+func (c *dbCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.db.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquire, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+
+	stats, err := c.cachedStats()
+	if err != nil {
+		c.db.log.Errorf("failed to collect DB stats: %v", err)
+		return
+	}
+	for status, count := range stats.ordersByStatus {
+		ch <- prometheus.MustNewConstMetric(c.ordersByStatus, prometheus.GaugeValue, float64(count), status)
+	}
+	ch <- prometheus.MustNewConstMetric(c.pointsAccrued, prometheus.GaugeValue, stats.accrued)
+	ch <- prometheus.MustNewConstMetric(c.pointsWithdrawn, prometheus.CounterValue, stats.withdrawn)
+}
+
+// cachedStats returns the aggregates, querying them at most once per metricsCacheTTL.
+func (c *dbCollector) cachedStats() (*dbStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stats != nil && time.Since(c.stats.fetchedAt) < metricsCacheTTL {
+		return c.stats, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), metricsQueryTimeout)
+	defer cancel()
+
+	stats := &dbStats{fetchedAt: time.Now(), ordersByStatus: make(map[string]int64)}
+	const selectOrdersByStatus = `SELECT status, COUNT(*) FROM users_orders GROUP BY status;`
+	rows, err := c.db.pool.Query(ctx, selectOrdersByStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select orders by status: %w", err)
+	}
+	var status string
+	var count int64
+	_, err = pgx.ForEachRow(rows, []any{&status, &count}, func() error {
+		stats.ordersByStatus[status] = count
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select orders by status: %w", err)
+	}
+
+	const selectTotals = `
+	SELECT (SELECT COALESCE(SUM(accrual), 0) FROM users_orders),
+	       (SELECT COALESCE(SUM(sum), 0) FROM users_withdraw WHERE status = 'COMPLETED');`
+	if err := c.db.pool.QueryRow(ctx, selectTotals).Scan(&stats.accrued, &stats.withdrawn); err != nil {
+		return nil, fmt.Errorf("failed to select points totals: %w", err)
+	}
+
+	c.stats = stats
+	return stats, nil
+}
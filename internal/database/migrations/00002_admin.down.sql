@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS admin_audit_log;
+ALTER TABLE users DROP COLUMN IF EXISTS locked;
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS admin_audit_log(
+    id BIGSERIAL PRIMARY KEY,
+    operator VARCHAR(200) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    target VARCHAR(200) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at VARCHAR(25) NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS users_adjustments(
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(200) NOT NULL,
+    amount NUMERIC(10, 2) NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    reference VARCHAR(200) NOT NULL DEFAULT '',
+    operator VARCHAR(200) NOT NULL,
+    created_at VARCHAR(25) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS users_adjustments_user_id_idx ON users_adjustments (user_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_adjustments_reversal_idx ON users_adjustments (reason, reference)
+    WHERE reason IN ('WITHDRAWAL_REVERSAL', 'ACCRUAL_CLAWBACK');
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS users_orders_user_id_idx;
+DROP INDEX IF EXISTS users_withdraw_user_id_idx;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+CREATE INDEX IF NOT EXISTS users_orders_user_id_idx ON users_orders (user_id);
+CREATE INDEX IF NOT EXISTS users_withdraw_user_id_idx ON users_withdraw (user_id);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS accrual_lot_consumptions;
+DROP TABLE IF EXISTS accrual_lots;
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS accrual_lots(
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(200) NOT NULL,
+    order_number VARCHAR(200) NOT NULL UNIQUE,
+    amount NUMERIC(10, 2) NOT NULL,
+    remaining NUMERIC(10, 2) NOT NULL,
+    accrued_at VARCHAR(25) NOT NULL,
+    expires_at VARCHAR(25) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS accrual_lots_user_id_idx ON accrual_lots (user_id) WHERE remaining > 0;
+
+CREATE TABLE IF NOT EXISTS accrual_lot_consumptions(
+    lot_id BIGINT NOT NULL REFERENCES accrual_lots (id),
+    withdraw_number VARCHAR(200) NOT NULL,
+    amount NUMERIC(10, 2) NOT NULL,
+    PRIMARY KEY (lot_id, withdraw_number)
+);
+
+CREATE INDEX IF NOT EXISTS accrual_lot_consumptions_withdraw_idx ON accrual_lot_consumptions (withdraw_number);
+
+COMMIT;
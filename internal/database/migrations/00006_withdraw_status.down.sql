@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS users_withdraw_pending_idx;
+ALTER TABLE users_withdraw DROP COLUMN IF EXISTS status;
+
+COMMIT;
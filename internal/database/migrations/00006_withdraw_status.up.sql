@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users_withdraw ADD COLUMN IF NOT EXISTS status VARCHAR(50) NOT NULL DEFAULT 'COMPLETED';
+
+CREATE INDEX IF NOT EXISTS users_withdraw_pending_idx ON users_withdraw (user_id) WHERE status = 'PENDING';
+
+COMMIT;
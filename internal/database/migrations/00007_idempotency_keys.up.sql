@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+    user_id VARCHAR(200) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    content_type VARCHAR(200) NOT NULL DEFAULT '',
+    body BYTEA NOT NULL DEFAULT '',
+    created_at VARCHAR(25) NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
+
+COMMIT;
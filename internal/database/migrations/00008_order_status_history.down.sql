@@ -0,0 +1,5 @@
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS order_status_history;
+
+COMMIT;
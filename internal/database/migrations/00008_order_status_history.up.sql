@@ -0,0 +1,18 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS order_status_history(
+    id BIGSERIAL PRIMARY KEY,
+    order_number VARCHAR(200) NOT NULL,
+    old_status VARCHAR(50) NOT NULL DEFAULT '',
+    new_status VARCHAR(50) NOT NULL,
+    accrual NUMERIC(10, 2),
+    source VARCHAR(50) NOT NULL,
+    changed_at VARCHAR(25) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_number_idx ON order_status_history (order_number);
+
+INSERT INTO order_status_history (order_number, new_status, accrual, source, changed_at)
+SELECT number, status, accrual, 'migration', uploaded_at FROM users_orders;
+
+COMMIT;
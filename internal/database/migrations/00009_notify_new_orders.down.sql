@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+DROP TRIGGER IF EXISTS users_orders_notify_new ON users_orders;
+DROP FUNCTION IF EXISTS notify_new_order();
+
+COMMIT;
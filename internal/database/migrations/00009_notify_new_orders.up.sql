@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+CREATE OR REPLACE FUNCTION notify_new_order() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('new_orders', NEW.number);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_orders_notify_new ON users_orders;
+CREATE TRIGGER users_orders_notify_new AFTER INSERT OR UPDATE OF status ON users_orders
+    FOR EACH ROW WHEN (NEW.status = 'NEW') EXECUTE FUNCTION notify_new_order();
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS users_orders_due_idx;
+
+COMMIT;
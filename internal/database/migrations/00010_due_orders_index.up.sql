@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+CREATE INDEX IF NOT EXISTS users_orders_due_idx ON users_orders (user_id, uploaded_at)
+    WHERE status IN ('NEW', 'PROCESSING');
+
+COMMIT;
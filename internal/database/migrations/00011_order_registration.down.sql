@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS order_goods;
+DROP INDEX IF EXISTS users_orders_registration_idx;
+ALTER TABLE users_orders DROP COLUMN IF EXISTS registration_next_at;
+ALTER TABLE users_orders DROP COLUMN IF EXISTS registration_attempts;
+ALTER TABLE users_orders DROP COLUMN IF EXISTS registration;
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users_orders ADD COLUMN IF NOT EXISTS registration VARCHAR(20);
+ALTER TABLE users_orders ADD COLUMN IF NOT EXISTS registration_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE users_orders ADD COLUMN IF NOT EXISTS registration_next_at VARCHAR(25);
+
+CREATE INDEX IF NOT EXISTS users_orders_registration_idx ON users_orders (registration_next_at)
+    WHERE registration = 'PENDING';
+
+CREATE TABLE IF NOT EXISTS order_goods(
+    order_number VARCHAR(200) NOT NULL REFERENCES users_orders (number) ON DELETE CASCADE,
+    position INT NOT NULL,
+    description TEXT NOT NULL,
+    price NUMERIC(12, 2) NOT NULL,
+    PRIMARY KEY (order_number, position)
+);
+
+COMMIT;
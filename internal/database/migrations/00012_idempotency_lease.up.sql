@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until VARCHAR(25);
+UPDATE idempotency_keys SET locked_until = created_at WHERE locked_until IS NULL;
+ALTER TABLE idempotency_keys ALTER COLUMN locked_until SET NOT NULL;
+
+COMMIT;
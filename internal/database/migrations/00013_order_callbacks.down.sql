@@ -0,0 +1,5 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users_orders DROP COLUMN IF EXISTS last_callback_at;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users_orders ADD COLUMN IF NOT EXISTS last_callback_at VARCHAR(25);
+
+COMMIT;
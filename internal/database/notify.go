@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// newOrdersChannel is notified by a trigger on users_orders whenever an order becomes NEW.
+const newOrdersChannel = "new_orders"
+
+// ListenNewOrders signals wake whenever an order becomes NEW. Notifications sent while the listener
+// is disconnected are lost, so wake is also signaled after every (re)connect. The listener reconnects
+// with exponential backoff until ctx is done.
+func (db *DB) ListenNewOrders(ctx context.Context, wake chan<- struct{}) {
+	const (
+		minBackoff = time.Second
+		maxBackoff = 30 * time.Second
+	)
+
+	backoff := minBackoff
+	for {
+		connected, err := db.listen(ctx, wake)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = minBackoff
+		}
+		db.log.Errorf("new orders listener failed, reconnecting in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// listen holds a dedicated connection until it fails and reports whether LISTEN succeeded.
+func (db *DB) listen(ctx context.Context, wake chan<- struct{}) (bool, error) {
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+	// A listening connection must not go back to the pool.
+	defer func() {
+		if err := conn.Conn().Close(context.Background()); err != nil {
+			db.log.Infof("failed to close listener connection: %v", err)
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+newOrdersChannel); err != nil {
+		return false, fmt.Errorf("failed to listen on %s: %w", newOrdersChannel, err)
+	}
+	db.log.Infof("listening on %s", newOrdersChannel)
+
+	for {
+		notifyWake(wake)
+		if _, err := conn.Conn().WaitForNotification(ctx); err != nil {
+			return true, fmt.Errorf("failed to wait for notification: %w", err)
+		}
+	}
+}
+
+func notifyWake(wake chan<- struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/tiunovvv/gophermart/internal/logging"
 	"go.uber.org/zap"
 )
 
@@ -16,10 +17,27 @@ func newQueryTracer(logger *zap.SugaredLogger) *queryTracer {
 }
 
 func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
-	t.log.Infof("Running query %s (%v)", data.SQL, data.Args)
+	t.log.Debugw("running query", "sql", data.SQL, "args", redactArgs(data.SQL, data.Args))
 	return ctx
 }
 
 func (t *queryTracer) TraceQueryEnd(_ context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
-	t.log.Infof("%v", data.CommandTag)
+	if data.Err != nil {
+		t.log.Warnw("query failed", "error", data.Err)
+		return
+	}
+	t.log.Debugw("query done", "tag", data.CommandTag.String())
+}
+
+// redactArgs hides all arguments of statements that touch sensitive columns such as pswd_hash,
+// since positional arguments cannot be matched to column names reliably.
+func redactArgs(sql string, args []any) []any {
+	if !logging.IsSensitive(sql) {
+		return args
+	}
+	redacted := make([]any, len(args))
+	for i := range redacted {
+		redacted[i] = logging.Redacted
+	}
+	return redacted
 }
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+func (db *DB) insertGoods(ctx context.Context, tx pgx.Tx, number string, goods []models.Good) error {
+	if len(goods) == 0 {
+		return nil
+	}
+
+	descriptions := make([]string, 0, len(goods))
+	prices := make([]float64, 0, len(goods))
+	for _, good := range goods {
+		descriptions = append(descriptions, good.Description)
+		prices = append(prices, good.Price)
+	}
+
+	const insertGoods = `
+	INSERT INTO order_goods (order_number, position, description, price)
+	SELECT $1, position, description, price
+	FROM unnest($2::text[], $3::numeric[]) WITH ORDINALITY AS g(description, price, position);`
+	if _, err := tx.Exec(ctx, insertGoods, number, descriptions, prices); err != nil {
+		return fmt.Errorf("failed to insert goods of order=%s: %w", number, err)
+	}
+	return nil
+}
+
+// GetDueRegistrations returns up to limit orders whose registration in the accrual system is due by now,
+// together with their goods.
+func (db *DB) GetDueRegistrations(ctx context.Context, limit int, now time.Time) ([]models.Registration, error) {
+	const selectDue = `
+	SELECT number, registration_attempts FROM users_orders
+	WHERE registration = 'PENDING' AND registration_next_at::timestamptz <= $2
+	ORDER BY registration_next_at LIMIT $1;`
+	rows, err := db.pool.Query(ctx, selectDue, limit, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select due registrations: %w", err)
+	}
+	registrations, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Registration, error) {
+		var registration models.Registration
+		err := row.Scan(&registration.Number, &registration.Attempts)
+		return registration, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select due registrations: %w", err)
+	}
+	if len(registrations) == 0 {
+		return nil, nil
+	}
+
+	numbers := make([]string, 0, len(registrations))
+	byNumber := make(map[string]*models.Registration, len(registrations))
+	for i := range registrations {
+		numbers = append(numbers, registrations[i].Number)
+		byNumber[registrations[i].Number] = &registrations[i]
+	}
+
+	const selectGoods = `
+	SELECT order_number, description, price FROM order_goods
+	WHERE order_number = ANY($1) ORDER BY order_number, position;`
+	rows, err = db.pool.Query(ctx, selectGoods, numbers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select goods: %w", err)
+	}
+	var number string
+	var good models.Good
+	_, err = pgx.ForEachRow(rows, []any{&number, &good.Description, &good.Price}, func() error {
+		byNumber[number].Goods = append(byNumber[number].Goods, good)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to select goods: %w", err)
+	}
+	return registrations, nil
+}
+
+// SetRegistration moves the order to a final registration state. A FAILED registration also moves the
+// order to INVALID, since it will never be processed.
+func (db *DB) SetRegistration(ctx context.Context, number string, state string) error {
+	return db.inTx(ctx, func(tx pgx.Tx) error {
+		var current models.OrderStatus
+		const selectStatus = `SELECT status FROM users_orders WHERE number = $1 FOR UPDATE;`
+		if err := tx.QueryRow(ctx, selectStatus, number).Scan(&current); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return myErrors.ErrOrderNotFound
+			}
+			return fmt.Errorf("failed to get status of order=%s: %w", number, err)
+		}
+
+		const updateRegistration = `UPDATE users_orders SET registration = $2 WHERE number = $1;`
+		if _, err := tx.Exec(ctx, updateRegistration, number, state); err != nil {
+			return fmt.Errorf("failed to set registration of order=%s: %w", number, err)
+		}
+		if state != models.RegistrationFailed || !current.CanTransitionTo(models.OrderInvalid) {
+			return nil
+		}
+
+		const updateStatus = `UPDATE users_orders SET status = $2 WHERE number = $1;`
+		if _, err := tx.Exec(ctx, updateStatus, number, models.OrderInvalid); err != nil {
+			return fmt.Errorf("failed to invalidate order=%s: %w", number, err)
+		}
+		return db.insertHistory(ctx, tx, number, current, models.OrderInvalid, nil, models.HistorySourceRegistration)
+	})
+}
+
+// DelayRegistration records a failed registration attempt and when to try again.
+func (db *DB) DelayRegistration(ctx context.Context, number string, attempts int, nextAt time.Time) error {
+	const delayRegistration = `
+	UPDATE users_orders SET registration_attempts = $2, registration_next_at = $3
+	WHERE number = $1 AND registration = 'PENDING';`
+	_, err := db.pool.Exec(ctx, delayRegistration, number, attempts, nextAt.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to delay registration of order=%s: %w", number, err)
+	}
+	return nil
+}
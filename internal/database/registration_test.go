@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+func TestSetRegistration(t *testing.T) {
+	tests := []struct {
+		name       string
+		state      string
+		wantStatus models.OrderStatus
+	}{
+		{name: "registered order stays new", state: models.RegistrationRegistered, wantStatus: models.OrderNew},
+		{name: "failed order becomes invalid", state: models.RegistrationFailed, wantStatus: models.OrderInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+			mustExec(t, db, `INSERT INTO users (user_id, login, pswd_hash) VALUES ('user-1', 'alice', '');`)
+			mustExec(t, db, `
+			INSERT INTO users_orders (number, status, user_id, uploaded_at, registration)
+			VALUES ('o1', 'NEW', 'user-1', $1, 'PENDING');`, time.Now().Format(time.RFC3339))
+
+			if err := db.SetRegistration(ctx, "o1", tt.state); err != nil {
+				t.Fatalf("SetRegistration: %v", err)
+			}
+
+			var status models.OrderStatus
+			var registration string
+			const selectOrder = `SELECT status, registration FROM users_orders WHERE number = 'o1';`
+			if err := db.pool.QueryRow(ctx, selectOrder).Scan(&status, &registration); err != nil {
+				t.Fatalf("failed to get order: %v", err)
+			}
+			if status != tt.wantStatus || registration != tt.state {
+				t.Errorf("order = %s/%s, want %s/%s", status, registration, tt.wantStatus, tt.state)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+type StatementWriter interface {
+	WriteOpening(balance float64) error
+	WriteEntry(entry models.StatementEntry) error
+}
+
+// StreamStatement writes the opening balance and then every ledger entry of the period row by row,
+// without loading the history into memory. Both reads see the same snapshot.
+func (db *DB) StreamStatement(ctx context.Context, userID string, period models.Period, w StatementWriter) error {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			db.log.Infof("failed to rollback: %v", err)
+		}
+	}()
+
+	var opening float64
+	const selectOpening = ledgerCTE + `
+	SELECT COALESCE(SUM(amount), 0) FROM ledger
+	WHERE $2::timestamptz IS NOT NULL AND created_at < $2;`
+	if err := tx.QueryRow(ctx, selectOpening, userID, period.From).Scan(&opening); err != nil {
+		return fmt.Errorf("failed to get opening balance: %w", err)
+	}
+	if err := w.WriteOpening(opening); err != nil {
+		return fmt.Errorf("failed to write opening balance: %w", err)
+	}
+
+	// Statement entries keep the status of orders and withdrawals in Reason, see statementEntry.
+	const selectEntries = ledgerCTE + `
+	SELECT type, reference, CASE WHEN type = 'ADJUSTMENT' THEN reason ELSE status END, amount, created_at,
+		uploaded_at
+	FROM ledger
+	WHERE ($2::timestamptz IS NULL OR created_at >= $2) AND ($3::timestamptz IS NULL OR created_at < $3)
+	ORDER BY created_at, type, reference, id;`
+	rows, err := tx.Query(ctx, selectEntries, userID, period.From, period.To)
+	if err != nil {
+		return fmt.Errorf("failed to select statement entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction models.Transaction
+		var uploadedAt *time.Time
+		if err := rows.Scan(&transaction.Type, &transaction.Reference, &transaction.Reason,
+			&transaction.Amount, &transaction.CreatedAt, &uploadedAt); err != nil {
+			return fmt.Errorf("failed to scan statement entry: %w", err)
+		}
+		if err := w.WriteEntry(statementEntry(transaction, uploadedAt)); err != nil {
+			return fmt.Errorf("failed to write statement entry: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read statement entries: %w", err)
+	}
+
+	return nil
+}
+
+// statementEntry maps a ledger row to the shape of its source table. For accruals and withdrawals Reason
+// holds the status; withdrawals are negative in the ledger and positive in their table. An accrual is
+// dated by its processing, uploadedAt is the upload time of its order.
+func statementEntry(t models.Transaction, uploadedAt *time.Time) models.StatementEntry {
+	entry := models.StatementEntry{Type: t.Type}
+	switch t.Type {
+	case models.TransactionAccrual:
+		processedAt := t.CreatedAt
+		entry.Accrual = &models.OrderWithTime{
+			ProcessedAt: &processedAt,
+			Number:      t.Reference,
+			Status:      models.OrderStatus(t.Reason),
+			Accrual:     t.Amount,
+		}
+		if uploadedAt != nil {
+			entry.Accrual.UploadedAt = *uploadedAt
+		}
+	case models.TransactionWithdrawal:
+		entry.Withdrawal = &models.Withdrawals{
+			ProcessedAt: t.CreatedAt,
+			Order:       t.Reference,
+			Status:      t.Reason,
+			Sum:         -t.Amount,
+		}
+	default:
+		entry.Adjustment = &t
+	}
+	return entry
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+func TestStatementEntry(t *testing.T) {
+	uploadedAt := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	at := uploadedAt.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		row   models.Transaction
+		check func(t *testing.T, entry models.StatementEntry)
+	}{
+		{
+			name: "accrual",
+			row:  models.Transaction{Type: models.TransactionAccrual, Reference: "o1", Reason: "PROCESSED", Amount: 500, CreatedAt: at},
+			check: func(t *testing.T, entry models.StatementEntry) {
+				if entry.Accrual == nil || entry.Accrual.Accrual != 500 || entry.Accrual.Status != models.OrderProcessed {
+					t.Fatalf("accrual = %+v, want 500 PROCESSED", entry.Accrual)
+				}
+				if !entry.Accrual.ProcessedAt.Equal(at) || !entry.Accrual.UploadedAt.Equal(uploadedAt) {
+					t.Errorf("accrual processed at %v, uploaded at %v, want %v and %v",
+						entry.Accrual.ProcessedAt, entry.Accrual.UploadedAt, at, uploadedAt)
+				}
+			},
+		},
+		{
+			name: "withdrawal",
+			row:  models.Transaction{Type: models.TransactionWithdrawal, Reference: "w1", Reason: "COMPLETED", Amount: -120, CreatedAt: at},
+			check: func(t *testing.T, entry models.StatementEntry) {
+				if entry.Withdrawal == nil || entry.Withdrawal.Sum != 120 || entry.Withdrawal.Status != models.WithdrawCompleted {
+					t.Errorf("withdrawal = %+v, want sum 120 COMPLETED", entry.Withdrawal)
+				}
+			},
+		},
+		{
+			name: "adjustment",
+			row:  models.Transaction{Type: models.TransactionAdjustment, Reference: "o1", Reason: models.ReasonAccrualClawback, Amount: -500, CreatedAt: at},
+			check: func(t *testing.T, entry models.StatementEntry) {
+				if entry.Adjustment == nil || entry.Adjustment.Reason != models.ReasonAccrualClawback {
+					t.Errorf("adjustment = %+v, want ACCRUAL_CLAWBACK", entry.Adjustment)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := statementEntry(tt.row, &uploadedAt)
+			if entry.Amount() != tt.row.Amount {
+				t.Errorf("Amount() = %v, want the ledger amount %v", entry.Amount(), tt.row.Amount)
+			}
+			tt.check(t, entry)
+		})
+	}
+}
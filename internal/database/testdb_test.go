@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/tiunovvv/gophermart/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// newTestDB connects to the database in TEST_DATABASE_URI, applies the migrations and empties every
+// table. Tests that need it are skipped when the variable is not set.
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	uri := os.Getenv("TEST_DATABASE_URI")
+	if uri == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+
+	ctx := context.Background()
+	db, err := NewDB(ctx, uri, zap.NewNop().Sugar(), metrics.NewMetrics())
+	if err != nil {
+		t.Fatalf("failed to connect to the test database: %v", err)
+	}
+	t.Cleanup(db.pool.Close)
+
+	const truncate = `
+	TRUNCATE users, users_orders, users_withdraw, users_adjustments, accrual_lots, accrual_lot_consumptions,
+		admin_audit_log, idempotency_keys, order_status_history, order_goods;`
+	if _, err := db.pool.Exec(ctx, truncate); err != nil {
+		t.Fatalf("failed to truncate tables: %v", err)
+	}
+	return db
+}
+
+// mustExec runs a setup statement.
+func mustExec(t *testing.T, db *DB, sql string, args ...any) {
+	t.Helper()
+	if _, err := db.pool.Exec(context.Background(), sql, args...); err != nil {
+		t.Fatalf("failed to run %q: %v", sql, err)
+	}
+}
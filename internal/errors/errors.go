@@ -8,4 +8,16 @@ var (
 	ErrOrderSavedByOtherUser = errors.New("order was saved by other user")
 	ErrWithdrawAlreadySaved  = errors.New("withdraw URL already saved")
 	ErrNoMoney               = errors.New("no money")
+	ErrUserNotFound          = errors.New("user not found")
+	ErrUserLocked            = errors.New("user is locked")
+	ErrOrderNotFound         = errors.New("order not found")
+	ErrWithdrawNotFound      = errors.New("withdraw not found")
+	ErrAlreadyAdjusted       = errors.New("adjustment already applied")
+	ErrNothingToAdjust       = errors.New("nothing to adjust")
+	ErrOrderClawedBack       = errors.New("order accrual was clawed back")
+	ErrInvalidAdjustment     = errors.New("invalid adjustment")
+	ErrWithdrawNotPending    = errors.New("withdraw is not pending")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key reused with another request")
+	ErrIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+	ErrIllegalTransition     = errors.New("illegal order status transition")
 )
@@ -0,0 +1,69 @@
+package expiration
+
+import (
+	"context"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/config"
+	"go.uber.org/zap"
+)
+
+// Clock lets tests move time forward without waiting for real lots to expire.
+type Clock interface {
+	Now() time.Time
+}
+
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+type Mart interface {
+	ExpirePoints(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Expirer periodically writes off accrued points whose lots are past their expiration date.
+type Expirer struct {
+	mart     Mart
+	clock    Clock
+	log      *zap.SugaredLogger
+	interval time.Duration
+}
+
+func NewExpirer(cfg *config.Config, mart Mart, log *zap.SugaredLogger, clock Clock) *Expirer {
+	return &Expirer{
+		mart:     mart,
+		clock:    clock,
+		log:      log,
+		interval: cfg.Expiration.CheckInterval,
+	}
+}
+
+// Start runs the expiration once right away and then every check interval until ctx is done.
+func (e *Expirer) Start(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.Run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run expires the lots that are due at the clock's current time.
+func (e *Expirer) Run(ctx context.Context) {
+	expired, err := e.mart.ExpirePoints(ctx, e.clock.Now())
+	if err != nil {
+		e.log.Errorf("failed to expire points: %v", err)
+		return
+	}
+	if expired > 0 {
+		e.log.Infow("points expired", "lots", expired)
+	}
+}
@@ -0,0 +1,110 @@
+package expiration
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/config"
+	"go.uber.org/zap"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// fakeMart records the times the expirer asked to expire points at.
+type fakeMart struct {
+	mu    sync.Mutex
+	calls []time.Time
+	err   error
+}
+
+func (m *fakeMart) ExpirePoints(_ context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, now)
+	return 1, m.err
+}
+
+func (m *fakeMart) called() []time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]time.Time(nil), m.calls...)
+}
+
+func newTestExpirer(mart Mart, clock Clock, interval time.Duration) *Expirer {
+	cfg := &config.Config{Expiration: config.ExpirationConfig{Enabled: true, CheckInterval: interval}}
+	return NewExpirer(cfg, mart, zap.NewNop().Sugar(), clock)
+}
+
+func TestRun(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "expires at the clock's time"},
+		{name: "failure is only logged", err: errors.New("connection refused")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: start}
+			mart := &fakeMart{err: tt.err}
+			e := newTestExpirer(mart, clock, time.Hour)
+
+			e.Run(context.Background())
+			clock.now = clock.now.Add(24 * time.Hour)
+			e.Run(context.Background())
+
+			calls := mart.called()
+			want := []time.Time{start, start.Add(24 * time.Hour)}
+			if len(calls) != len(want) {
+				t.Fatalf("calls = %v, want %v", calls, want)
+			}
+			for i := range want {
+				if !calls[i].Equal(want[i]) {
+					t.Errorf("call %d at %v, want %v", i, calls[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	mart := &fakeMart{}
+	e := newTestExpirer(mart, RealClock{}, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Start(ctx)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for len(mart.called()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expirer did not run right away")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expirer did not stop")
+	}
+	if calls := len(mart.called()); calls != 1 {
+		t.Errorf("calls = %d, want 1 before the first tick", calls)
+	}
+}
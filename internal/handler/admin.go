@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tiunovvv/gophermart/internal/mart"
+	"github.com/tiunovvv/gophermart/internal/models"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+)
+
+func (h *Handler) getOperator(c *gin.Context) string {
+	return c.GetString(gin.AuthUserKey)
+}
+
+func (h *Handler) GetLogLevels(c *gin.Context) {
+	c.JSON(http.StatusOK, h.logger.Levels())
+}
+
+func (h *Handler) SetLogLevel(c *gin.Context) {
+	var level models.LogLevel
+	if err := c.ShouldBindJSON(&level); err != nil {
+		h.log.Errorf("failed to decode request JSON body: %v", err)
+		c.AbortWithStatus(bodyErrorStatus(err))
+		return
+	}
+
+	if err := h.logger.SetLevel(level.Component, level.Level); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	details := fmt.Sprintf("component=%q level=%q", level.Component, level.Level)
+	if err := h.mart.Audit(c, h.getOperator(c), mart.ActionSetLogLevel, "log", details); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, h.logger.Levels())
+}
+
+func (h *Handler) AdminGetUser(c *gin.Context) {
+	user, err := h.mart.FindUser(c, h.getOperator(c), c.Param("login"))
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *Handler) AdminGetUserOrders(c *gin.Context) {
+	orders, err := h.mart.FindUserOrders(c, h.getOperator(c), c.Param("login"))
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	if len(orders) == 0 {
+		c.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, orders)
+}
+
+func (h *Handler) AdminGetUserWithdrawals(c *gin.Context) {
+	withdrawals, err := h.mart.FindUserWithdrawals(c, h.getOperator(c), c.Param("login"))
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	if len(withdrawals) == 0 {
+		c.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, withdrawals)
+}
+
+func (h *Handler) AdminLockUser(c *gin.Context) {
+	err := h.mart.SetUserLocked(c, h.getOperator(c), c.Param("login"), true)
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) AdminUnlockUser(c *gin.Context) {
+	err := h.mart.SetUserLocked(c, h.getOperator(c), c.Param("login"), false)
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) AdminResetOrder(c *gin.Context) {
+	err := h.mart.ResetOrder(c, h.getOperator(c), c.Param("number"))
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) AdminInvalidateOrder(c *gin.Context) {
+	err := h.mart.InvalidateOrder(c, h.getOperator(c), c.Param("number"))
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) AdminSaveAdjustment(c *gin.Context) {
+	var adjustment models.Adjustment
+	if err := c.ShouldBindJSON(&adjustment); err != nil {
+		h.log.Errorf("failed to decode request JSON body: %v", err)
+		c.AbortWithStatus(bodyErrorStatus(err))
+		return
+	}
+
+	err := h.mart.SaveAdjustment(c, h.getOperator(c), c.Param("login"), adjustment)
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) AdminRefundWithdraw(c *gin.Context) {
+	err := h.mart.RefundWithdraw(c, h.getOperator(c), c.Param("number"))
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) AdminApproveWithdraw(c *gin.Context) {
+	err := h.mart.ApproveWithdraw(c, h.getOperator(c), c.Param("number"))
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) AdminRejectWithdraw(c *gin.Context) {
+	err := h.mart.RejectWithdraw(c, h.getOperator(c), c.Param("number"))
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) AdminClawbackOrder(c *gin.Context) {
+	err := h.mart.ClawbackOrder(c, h.getOperator(c), c.Param("number"))
+	if h.abortOnAdminError(c, err) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) abortOnAdminError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, myErrors.ErrUserNotFound),
+		errors.Is(err, myErrors.ErrOrderNotFound),
+		errors.Is(err, myErrors.ErrWithdrawNotFound):
+		c.AbortWithStatus(http.StatusNotFound)
+	case errors.Is(err, myErrors.ErrAlreadyAdjusted), errors.Is(err, myErrors.ErrWithdrawNotPending),
+		errors.Is(err, myErrors.ErrOrderClawedBack):
+		c.AbortWithStatus(http.StatusConflict)
+	case errors.Is(err, myErrors.ErrNoMoney):
+		c.AbortWithStatus(http.StatusPaymentRequired)
+	case errors.Is(err, myErrors.ErrInvalidAdjustment), errors.Is(err, myErrors.ErrNothingToAdjust):
+		c.AbortWithStatus(http.StatusUnprocessableEntity)
+	default:
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+	return true
+}
@@ -12,8 +12,6 @@ import (
 	"github.com/tiunovvv/gophermart/internal/models"
 )
 
-const maxAge = 3600 * 24 * 30
-
 func (h *Handler) Register(c *gin.Context) {
 	h.authenticateUser(c, "register")
 }
@@ -27,7 +25,7 @@ func (h *Handler) authenticateUser(c *gin.Context, action string) {
 
 	if err := c.ShouldBindJSON(&user); err != nil {
 		h.log.Error("failed to decode request JSON body: %w", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		c.AbortWithStatus(bodyErrorStatus(err))
 		return
 	}
 
@@ -42,6 +40,10 @@ func (h *Handler) authenticateUser(c *gin.Context, action string) {
 		}
 	case "login":
 		userID, err = h.mart.GetUserID(c, user)
+		if errors.Is(err, myErrors.ErrUserLocked) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
 		if err != nil {
 			h.log.Error("failed to login: %w", err)
 			c.AbortWithStatus(http.StatusUnauthorized)
@@ -56,6 +58,7 @@ func (h *Handler) authenticateUser(c *gin.Context, action string) {
 		return
 	}
 
+	maxAge := int(h.cfg.Auth.CookieMaxAge.Seconds())
 	c.SetCookie("Authorization", token, maxAge, "", "", false, true)
 	c.Status(http.StatusOK)
 }
@@ -84,3 +87,26 @@ func (h *Handler) getUserID(c *gin.Context) string {
 	}
 	return userID
 }
+
+func (h *Handler) RequireActiveUser(c *gin.Context) {
+	userID := h.getUserID(c)
+	if len(userID) == 0 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	locked, err := h.mart.IsUserLocked(c, userID)
+	if errors.Is(err, myErrors.ErrUserNotFound) {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if locked {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchOrders caps the number of order numbers in one batch upload.
+const maxBatchOrders = 1000
+
+var errTooManyOrders = fmt.Errorf("batch exceeds %d orders", maxBatchOrders)
+
+// SaveOrders uploads a JSON array or newline-delimited list of order numbers and returns
+// the result for each of them in request order.
+func (h *Handler) SaveOrders(c *gin.Context) {
+	userID := h.getUserID(c)
+	if len(userID) == 0 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatus(bodyErrorStatus(err))
+		return
+	}
+
+	var numbers []string
+	if c.ContentType() == gin.MIMEJSON {
+		numbers, err = parseJSONNumbers(body)
+	} else {
+		numbers, err = parseTextNumbers(body)
+	}
+	if errors.Is(err, errTooManyOrders) {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("failed to parse order numbers: %v", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if len(numbers) == 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.mart.SaveOrders(c, userID, numbers)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// parseJSONNumbers accepts order numbers as JSON strings or JSON numbers.
+func parseJSONNumbers(body []byte) ([]string, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON array: %w", err)
+	}
+	if len(items) > maxBatchOrders {
+		return nil, errTooManyOrders
+	}
+
+	numbers := make([]string, 0, len(items))
+	for _, item := range items {
+		var number string
+		if err := json.Unmarshal(item, &number); err == nil {
+			numbers = append(numbers, normalizeOrderNumber(number))
+			continue
+		}
+		var n json.Number
+		if err := json.Unmarshal(item, &n); err != nil {
+			return nil, fmt.Errorf("order number %s is neither a string nor a number", item)
+		}
+		numbers = append(numbers, n.String())
+	}
+	return numbers, nil
+}
+
+func parseTextNumbers(body []byte) ([]string, error) {
+	var numbers []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		number := normalizeOrderNumber(scanner.Text())
+		if number == "" {
+			continue
+		}
+		if len(numbers) == maxBatchOrders {
+			return nil, errTooManyOrders
+		}
+		numbers = append(numbers, number)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read order numbers: %w", err)
+	}
+	return numbers, nil
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+	"github.com/tiunovvv/gophermart/internal/models"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+)
+
+// AccrualCallback applies an order status pushed by the accrual system. The payload is an order as
+// returned by GET /api/orders/{number}, REGISTERED is accepted as PROCESSING.
+func (h *Handler) AccrualCallback(c *gin.Context) {
+	var order models.Order
+	if err := json.NewDecoder(c.Request.Body).Decode(&order); err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	status, ok := accrualclient.Status(order.Status).OrderStatus()
+	if !ok || order.Order == "" || order.Accrual < 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	order.Status = status
+
+	err := h.mart.ApplyAccrualCallback(c, order)
+	switch {
+	case errors.Is(err, myErrors.ErrOrderNotFound):
+		c.AbortWithStatus(http.StatusNotFound)
+	case errors.Is(err, myErrors.ErrIllegalTransition):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.AbortWithStatus(http.StatusInternalServerError)
+	default:
+		c.Status(http.StatusOK)
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tiunovvv/gophermart/internal/config"
+	"github.com/tiunovvv/gophermart/internal/health"
+	"github.com/tiunovvv/gophermart/internal/logging"
+	"github.com/tiunovvv/gophermart/internal/metrics"
+	"github.com/tiunovvv/gophermart/internal/models"
+	"github.com/tiunovvv/gophermart/internal/ordernum"
+)
+
+// fakeMart implements the calls made on the order upload and withdrawal paths; any other call panics.
+type fakeMart struct {
+	Mart
+	number         string
+	goods          []models.Good
+	withdraw       *models.Withdraw
+	withdrawStatus string
+}
+
+func (m *fakeMart) IsUserLocked(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+func (m *fakeMart) CheckOrderNumber(number string) bool {
+	return ordernum.Luhn{Bounds: ordernum.Bounds{MinLen: 2, MaxLen: 19}}.Valid(number)
+}
+
+func (m *fakeMart) SaveOrder(_ context.Context, _ string, number string, goods []models.Good) error {
+	m.number, m.goods = number, goods
+	return nil
+}
+
+func newTestRouter(t *testing.T, mart Mart) (*gin.Engine, *http.Cookie) {
+	t.Helper()
+	return newTestRouterWithConfig(t, mart, func(*config.Config) {})
+}
+
+// newTestRouterWithConfig lets configure change the loaded config before the routes are built.
+func newTestRouterWithConfig(t *testing.T, mart Mart, configure func(*config.Config)) (*gin.Engine, *http.Cookie) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SECRET", "test-secret")
+
+	cfg, err := config.Load([]string{"-d", "postgres://localhost/test"})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	cfg.Log.Level = "error"
+	configure(cfg)
+	logger, err := logging.NewLogger(cfg.Log)
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	token, err := getToken("user-1")
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	h := NewHandler(cfg, mart, logger)
+	router := h.InitRoutes(metrics.NewMetrics(), health.NewChecker(nil, nil, ""))
+	return router, &http.Cookie{Name: "Authorization", Value: token}
+}
+
+func (m *fakeMart) SaveWithdraw(_ context.Context, _ string, withdraw models.Withdraw) (string, error) {
+	m.withdraw = &withdraw
+	return m.withdrawStatus, nil
+}
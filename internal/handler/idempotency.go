@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tiunovvv/gophermart/internal/models"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+)
+
+const (
+	idempotencyKeyHeader   = "Idempotency-Key"
+	idempotentReplayHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLen   = 255
+)
+
+// recordingWriter keeps a copy of the response body for storing it under the idempotency key.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotent replays the stored response when a request is retried with the same Idempotency-Key.
+// Requests without the header are passed through. Server errors are not stored, so they can be retried.
+func (h *Handler) Idempotent(c *gin.Context) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		c.Next()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	userID := h.getUserID(c)
+	if len(userID) == 0 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		h.log.Errorf("failed to read request body: %v", err)
+		c.AbortWithStatus(bodyErrorStatus(err))
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.FullPath() + "\n"))
+	hash.Write(body)
+	requestHash := hex.EncodeToString(hash.Sum(nil))
+
+	stored, err := h.mart.ReserveIdempotencyKey(c, userID, key, requestHash)
+	switch {
+	case errors.Is(err, myErrors.ErrIdempotencyKeyReused), errors.Is(err, myErrors.ErrIdempotencyInProgress):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.log.Errorf("failed to reserve idempotency key: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	case stored != nil:
+		c.Header(idempotentReplayHeader, "true")
+		c.Data(stored.Status, stored.ContentType, stored.Body)
+		c.Abort()
+		return
+	}
+
+	w := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.Next()
+
+	// The response is already sent: the key is stored even if the client has gone away.
+	ctx := context.WithoutCancel(c)
+	status := w.Status()
+	if status >= http.StatusInternalServerError {
+		if err := h.mart.ReleaseIdempotencyKey(ctx, userID, key); err != nil {
+			h.log.Errorf("failed to release idempotency key: %v", err)
+		}
+		return
+	}
+	err = h.mart.SaveIdempotentResponse(ctx, userID, key, models.IdempotentResponse{
+		Status:      status,
+		ContentType: w.Header().Get("Content-Type"),
+		Body:        w.body.Bytes(),
+	})
+	if err == nil {
+		return
+	}
+	h.log.Errorf("failed to save idempotent response, releasing the key: %v", err)
+	if err := h.mart.ReleaseIdempotencyKey(ctx, userID, key); err != nil {
+		h.log.Errorf("failed to release idempotency key: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/tiunovvv/gophermart/internal/database"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+// Mart is the part of mart.Mart used by the handlers.
+type Mart interface {
+	NewUser(ctx context.Context, user models.User) (string, error)
+	GetUserID(ctx context.Context, user models.User) (string, error)
+	IsUserLocked(ctx context.Context, userID string) (bool, error)
+
+	CheckOrderNumber(number string) bool
+	SaveOrder(ctx context.Context, userID string, number string, goods []models.Good) error
+	SaveOrders(ctx context.Context, userID string, numbers []string) ([]models.UploadResult, error)
+	GetOrdersForUser(ctx context.Context, userID string) ([]models.OrderWithTime, error)
+	GetOrderForUser(ctx context.Context, userID string, number string) (models.OrderDetails, error)
+	ApplyAccrualCallback(ctx context.Context, order models.Order) error
+
+	GetBalance(ctx context.Context, userID string) (models.Balance, error)
+	SaveWithdraw(ctx context.Context, userID string, withdraw models.Withdraw) (string, error)
+	CancelWithdraw(ctx context.Context, userID string, number string) error
+	GetWindrawalsForUser(ctx context.Context, userID string) ([]models.Withdrawals, error)
+	GetTransactionsForUser(
+		ctx context.Context,
+		userID string,
+		period models.Period,
+		page models.Page,
+	) ([]models.Transaction, error)
+	StreamStatement(ctx context.Context, userID string, period models.Period, w database.StatementWriter) error
+
+	ReserveIdempotencyKey(
+		ctx context.Context,
+		userID string,
+		key string,
+		requestHash string,
+	) (*models.IdempotentResponse, error)
+	SaveIdempotentResponse(ctx context.Context, userID string, key string, response models.IdempotentResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, userID string, key string) error
+
+	FindUser(ctx context.Context, operator string, login string) (models.UserInfo, error)
+	FindUserOrders(ctx context.Context, operator string, login string) ([]models.OrderWithTime, error)
+	FindUserWithdrawals(ctx context.Context, operator string, login string) ([]models.Withdrawals, error)
+	SetUserLocked(ctx context.Context, operator string, login string, locked bool) error
+	ResetOrder(ctx context.Context, operator string, number string) error
+	InvalidateOrder(ctx context.Context, operator string, number string) error
+	ClawbackOrder(ctx context.Context, operator string, number string) error
+	SaveAdjustment(ctx context.Context, operator string, login string, adjustment models.Adjustment) error
+	RefundWithdraw(ctx context.Context, operator string, number string) error
+	ApproveWithdraw(ctx context.Context, operator string, number string) error
+	RejectWithdraw(ctx context.Context, operator string, number string) error
+	Audit(ctx context.Context, operator string, action string, target string, details string) error
+}
@@ -1,45 +1,63 @@
 package handler
 
 import (
+	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tiunovvv/gophermart/internal/config"
-	"github.com/tiunovvv/gophermart/internal/mart"
+	"github.com/tiunovvv/gophermart/internal/logging"
+	"github.com/tiunovvv/gophermart/internal/models"
 	"go.uber.org/zap"
 
 	myErrors "github.com/tiunovvv/gophermart/internal/errors"
 )
 
 type Handler struct {
-	cfg  *config.Config
-	mart *mart.Mart
-	log  *zap.SugaredLogger
+	cfg    *config.Config
+	mart   Mart
+	logger *logging.Logger
+	log    *zap.SugaredLogger
 }
 
-func NewHandler(cfg *config.Config, mart *mart.Mart, log *zap.SugaredLogger) *Handler {
+func NewHandler(cfg *config.Config, mart Mart, logger *logging.Logger) *Handler {
 	return &Handler{
-		cfg:  cfg,
-		mart: mart,
-		log:  log,
+		cfg:    cfg,
+		mart:   mart,
+		logger: logger,
+		log:    logger.Component(logging.HTTP),
 	}
 }
 
+// SaveOrder uploads an order given as a plain-text number or as JSON with goods,
+// see models.OrderWithGoods.
 func (h *Handler) SaveOrder(c *gin.Context) {
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
+		c.AbortWithStatus(bodyErrorStatus(err))
 		return
 	}
-	if len(body) == 0 {
+
+	var number string
+	var goods []models.Good
+	if c.ContentType() == gin.MIMEJSON {
+		var order models.OrderWithGoods
+		if err := json.Unmarshal(body, &order); err != nil || !validGoods(order.Goods) {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		number, goods = normalizeOrderNumber(order.Order), order.Goods
+	} else {
+		number = normalizeOrderNumber(string(body))
+	}
+	if len(number) == 0 {
 		c.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
 
-	number := string(body)
-	if !h.mart.CheckLunaAlgorithm(number) {
+	if !h.mart.CheckOrderNumber(number) {
 		c.AbortWithStatus(http.StatusUnprocessableEntity)
 		return
 	}
@@ -50,7 +68,7 @@ func (h *Handler) SaveOrder(c *gin.Context) {
 		return
 	}
 
-	err = h.mart.SaveOrder(c, userID, number)
+	err = h.mart.SaveOrder(c, userID, number, goods)
 
 	if errors.Is(err, myErrors.ErrOrderSavedByThisUser) {
 		c.Status(http.StatusOK)
@@ -90,6 +108,26 @@ func (h *Handler) GetOrders(c *gin.Context) {
 	c.JSON(http.StatusOK, orders)
 }
 
+func (h *Handler) GetOrder(c *gin.Context) {
+	userID := h.getUserID(c)
+	if len(userID) == 0 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	order, err := h.mart.GetOrderForUser(c, userID, c.Param("number"))
+	if errors.Is(err, myErrors.ErrOrderNotFound) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.log.Errorf("failed to get order: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
 func (h *Handler) GetBalance(c *gin.Context) {
 	userID := h.getUserID(c)
 	if len(userID) == 0 {
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSaveOrder(t *testing.T) {
+	const number = "12345678903"
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		// chunked hides the length of the body, so only reading it hits the limit.
+		chunked    bool
+		wantStatus int
+		wantNumber string
+		wantGoods  int
+	}{
+		{name: "plain number", contentType: "text/plain", body: number,
+			wantStatus: http.StatusAccepted, wantNumber: number},
+		{name: "plain number with whitespace", contentType: "text/plain; charset=utf-8", body: "  " + number + "\r\n",
+			wantStatus: http.StatusAccepted, wantNumber: number},
+		{name: "json with goods", contentType: "application/json",
+			body:       `{"order": " ` + number + `\n", "goods": [{"description": "Чайник Bork", "price": 7000}]}`,
+			wantStatus: http.StatusAccepted, wantNumber: number, wantGoods: 1},
+		{name: "json without goods", contentType: "application/json", body: `{"order": "` + number + `"}`,
+			wantStatus: http.StatusBadRequest},
+		{name: "json with empty goods", contentType: "application/json", body: `{"order": "` + number + `", "goods": []}`,
+			wantStatus: http.StatusBadRequest},
+		{name: "whitespace only", contentType: "text/plain", body: " \n",
+			wantStatus: http.StatusBadRequest},
+		{name: "wrong checksum", contentType: "text/plain", body: "12345678904",
+			wantStatus: http.StatusUnprocessableEntity},
+		{name: "wrong content type", contentType: "application/xml", body: number,
+			wantStatus: http.StatusUnsupportedMediaType},
+		{name: "missing content type", body: number,
+			wantStatus: http.StatusUnsupportedMediaType},
+		{name: "plain over the limit", contentType: "text/plain", body: strings.Repeat(" ", orderBodyLimit) + number,
+			wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "json over the limit", contentType: "application/json", body: strings.Repeat(" ", orderBodyLimit+1),
+			wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "chunked over the limit", contentType: "text/plain", chunked: true,
+			body: strings.Repeat(" ", orderBodyLimit) + number, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mart := &fakeMart{}
+			router, cookie := newTestRouter(t, mart)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/user/orders", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			req.AddCookie(cookie)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if mart.number != tt.wantNumber {
+				t.Errorf("saved number = %q, want %q", mart.number, tt.wantNumber)
+			}
+			if len(mart.goods) != tt.wantGoods {
+				t.Errorf("saved %d goods, want %d", len(mart.goods), tt.wantGoods)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+	dateLayout       = "2006-01-02"
+)
+
+// parsePeriod reads from and to as RFC3339 timestamps or YYYY-MM-DD dates.
+func parsePeriod(c *gin.Context) (models.Period, error) {
+	var period models.Period
+	var err error
+	if period.From, err = parseTimeParam(c, "from"); err != nil {
+		return period, err
+	}
+	if period.To, err = parseTimeParam(c, "to"); err != nil {
+		return period, err
+	}
+	if period.From != nil && period.To != nil && !period.From.Before(*period.To) {
+		return period, fmt.Errorf("from must be before to")
+	}
+	return period, nil
+}
+
+// validGoods requires at least one good: an order registered without goods earns nothing.
+func validGoods(goods []models.Good) bool {
+	if len(goods) == 0 {
+		return false
+	}
+	for _, good := range goods {
+		if strings.TrimSpace(good.Description) == "" || good.Price < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTimeParam(c *gin.Context, name string) (*time.Time, error) {
+	value, ok := c.GetQuery(name)
+	if !ok || value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %q", name, value)
+	}
+	return &t, nil
+}
+
+func parsePage(c *gin.Context) (models.Page, error) {
+	page := models.Page{Limit: defaultPageLimit}
+
+	if value, ok := c.GetQuery("limit"); ok {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+		page.Limit = limit
+	}
+
+	if value, ok := c.GetQuery("offset"); ok {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return page, fmt.Errorf("offset must not be negative")
+		}
+		page.Offset = offset
+	}
+
+	return page, nil
+}
+
+// bodyErrorStatus tells a body over the route limit (413) from a malformed one (400).
+func bodyErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// normalizeOrderNumber drops whitespace around an order number, such as the trailing newline
+// added by command line clients.
+func normalizeOrderNumber(number string) string {
+	return strings.TrimSpace(number)
+}
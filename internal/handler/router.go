@@ -1,30 +1,87 @@
 package handler
 
 import (
-	"time"
-
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tiunovvv/gophermart/internal/health"
+	"github.com/tiunovvv/gophermart/internal/metrics"
 	"github.com/tiunovvv/gophermart/internal/middleware"
 )
 
-func (h *Handler) InitRoutes() *gin.Engine {
+// Body limits per route: a small JSON object, an order (a number or a number with goods)
+// and a batch of order numbers.
+const (
+	jsonBodyLimit  = 4 << 10
+	orderBodyLimit = 16 << 10
+	batchBodyLimit = 64 << 10
+)
+
+func (h *Handler) InitRoutes(m *metrics.Metrics, hc *health.Checker) *gin.Engine {
 	router := gin.New()
 
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})))
+	router.GET("/healthz", hc.Healthz)
+	router.GET("/livez", hc.Livez)
+	router.GET("/readyz", hc.Readyz)
+
 	router.Use(middleware.GinLogger(h.log))
-	const seconds = 5 * time.Second
-	router.Use(middleware.GinTimeOut(seconds, "timeout error"))
+	router.Use(middleware.GinMetrics(m))
+	// The statement is streamed for as long as the history takes, it runs outside the handler timeout and
+	// extends the write deadline itself.
+	router.GET("/api/user/statement", middleware.RequireAuth, h.RequireActiveUser, h.GetStatement)
+
+	router.Use(middleware.GinTimeOut(h.cfg.Server.HandlerTimeout, "timeout error"))
 
-	router.POST("/api/user/register", h.Register)
-	router.POST("/api/user/login", h.Login)
+	order := []gin.HandlerFunc{
+		middleware.RequireContentType(gin.MIMEPlain, gin.MIMEJSON),
+		middleware.LimitBody(orderBodyLimit),
+	}
+	jsonBody := []gin.HandlerFunc{middleware.RequireContentType(gin.MIMEJSON), middleware.LimitBody(jsonBodyLimit)}
+	batchBody := []gin.HandlerFunc{
+		middleware.RequireContentType(gin.MIMEJSON, gin.MIMEPlain),
+		middleware.LimitBody(batchBodyLimit),
+	}
 
-	authGroup := router.Group("/api/user").Use(middleware.RequireAuth)
+	router.POST("/api/user/register", append(jsonBody, h.Register)...)
+	router.POST("/api/user/login", append(jsonBody, h.Login)...)
 
-	authGroup.POST("orders", h.SaveOrder)
-	authGroup.POST("balance/withdraw", h.SaveWithdraw)
+	authGroup := router.Group("/api/user").Use(middleware.RequireAuth, h.RequireActiveUser)
+
+	authGroup.POST("orders", append(order, h.Idempotent, h.SaveOrder)...)
+	authGroup.POST("orders/batch", append(batchBody, h.SaveOrders)...)
+	authGroup.POST("balance/withdraw", append(jsonBody, h.Idempotent, h.SaveWithdraw)...)
+	authGroup.POST("withdrawals/:number/cancel", h.CancelWithdraw)
 
 	authGroup.GET("orders", h.GetOrders)
+	authGroup.GET("orders/:number", h.GetOrder)
 	authGroup.GET("balance", h.GetBalance)
 	authGroup.GET("withdrawals", h.GetWithdrawals)
+	authGroup.GET("transactions", h.GetTransactions)
+
+	if h.cfg.Accrual.CallbackSecret != "" {
+		router.POST("/api/internal/accrual/callback", append(jsonBody,
+			middleware.RequireSignature(h.cfg.Accrual.CallbackSecret), h.AccrualCallback)...)
+	}
+
+	if len(h.cfg.Admin.Accounts) > 0 {
+		adminGroup := router.Group("/api/admin", gin.BasicAuth(gin.Accounts(h.cfg.Admin.Accounts)))
+
+		adminGroup.GET("log/level", h.GetLogLevels)
+		adminGroup.PUT("log/level", append(jsonBody, h.SetLogLevel)...)
+
+		adminGroup.GET("users/:login", h.AdminGetUser)
+		adminGroup.GET("users/:login/orders", h.AdminGetUserOrders)
+		adminGroup.GET("users/:login/withdrawals", h.AdminGetUserWithdrawals)
+		adminGroup.POST("users/:login/lock", h.AdminLockUser)
+		adminGroup.POST("users/:login/unlock", h.AdminUnlockUser)
+		adminGroup.POST("orders/:number/reset", h.AdminResetOrder)
+		adminGroup.POST("orders/:number/invalidate", h.AdminInvalidateOrder)
+		adminGroup.POST("orders/:number/clawback", h.AdminClawbackOrder)
+		adminGroup.POST("users/:login/adjustments", append(jsonBody, h.AdminSaveAdjustment)...)
+		adminGroup.POST("withdrawals/:number/refund", h.AdminRefundWithdraw)
+		adminGroup.POST("withdrawals/:number/approve", h.AdminApproveWithdraw)
+		adminGroup.POST("withdrawals/:number/reject", h.AdminRejectWithdraw)
+	}
 
 	return router
 }
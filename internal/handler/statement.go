@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+const (
+	formatCSV  = "csv"
+	formatJSON = "json"
+)
+
+type statementWriter interface {
+	WriteOpening(balance float64) error
+	WriteEntry(entry models.StatementEntry) error
+	Close() error
+}
+
+func (h *Handler) GetStatement(c *gin.Context) {
+	userID := h.getUserID(c)
+	if len(userID) == 0 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	period, err := parsePeriod(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	out := &deadlineWriter{
+		w:       c.Writer,
+		rc:      http.NewResponseController(c.Writer),
+		timeout: h.cfg.Server.WriteTimeout,
+	}
+
+	var w statementWriter
+	switch format := c.DefaultQuery("format", formatJSON); format {
+	case formatCSV:
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="statement.csv"`)
+		w = newCSVStatementWriter(out)
+	case formatJSON:
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		w = newJSONStatementWriter(out)
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported format %q", format)})
+		return
+	}
+
+	c.Status(http.StatusOK)
+	if err := h.mart.StreamStatement(c, userID, period, w); err != nil {
+		h.log.Errorf("failed to export statement: %v", err)
+		c.Abort()
+		return
+	}
+	if err := w.Close(); err != nil {
+		h.log.Errorf("failed to finish statement: %v", err)
+		c.Abort()
+	}
+}
+
+// deadlineWriter moves the write deadline of the connection before every chunk, so a long statement is
+// bounded by how long one chunk takes rather than by the server write timeout.
+type deadlineWriter struct {
+	w       io.Writer
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	if d.timeout > 0 {
+		err := d.rc.SetWriteDeadline(time.Now().Add(d.timeout))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return 0, fmt.Errorf("failed to extend write deadline: %w", err)
+		}
+	}
+	return d.w.Write(p)
+}
+
+type csvStatementWriter struct {
+	w       *csv.Writer
+	balance float64
+}
+
+func newCSVStatementWriter(w io.Writer) *csvStatementWriter {
+	return &csvStatementWriter{w: csv.NewWriter(w)}
+}
+
+func (s *csvStatementWriter) WriteOpening(balance float64) error {
+	s.balance = balance
+	if err := s.w.Write([]string{"type", "date", "reference", "details", "amount", "balance"}); err != nil {
+		return fmt.Errorf("failed to write csv header: %w", err)
+	}
+	return s.write("OPENING_BALANCE", "", "", "", 0)
+}
+
+func (s *csvStatementWriter) WriteEntry(entry models.StatementEntry) error {
+	s.balance += entry.Amount()
+	switch {
+	case entry.Accrual != nil:
+		return s.write(entry.Type, formatTime(*entry.Accrual.ProcessedAt), entry.Accrual.Number,
+			string(entry.Accrual.Status), entry.Amount())
+	case entry.Withdrawal != nil:
+		return s.write(entry.Type, formatTime(entry.Withdrawal.ProcessedAt), entry.Withdrawal.Order,
+			entry.Withdrawal.Status, entry.Amount())
+	default:
+		return s.write(entry.Type, formatTime(entry.Adjustment.CreatedAt), entry.Adjustment.Reference,
+			entry.Adjustment.Reason, entry.Amount())
+	}
+}
+
+func (s *csvStatementWriter) Close() error {
+	if err := s.write("CLOSING_BALANCE", "", "", "", 0); err != nil {
+		return err
+	}
+	s.w.Flush()
+	if err := s.w.Error(); err != nil {
+		return fmt.Errorf("failed to flush csv: %w", err)
+	}
+	return nil
+}
+
+func (s *csvStatementWriter) write(kind, date, reference, details string, amount float64) error {
+	record := []string{
+		kind, date, reference, details,
+		formatAmount(amount),
+		formatAmount(s.balance),
+	}
+	if err := s.w.Write(record); err != nil {
+		return fmt.Errorf("failed to write csv record: %w", err)
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// jsonStatementWriter writes {"opening_balance":..,"entries":[..],"closing_balance":..} entry by entry.
+type jsonStatementWriter struct {
+	w       io.Writer
+	balance float64
+	count   int
+}
+
+func newJSONStatementWriter(w io.Writer) *jsonStatementWriter {
+	return &jsonStatementWriter{w: w}
+}
+
+func (s *jsonStatementWriter) WriteOpening(balance float64) error {
+	s.balance = balance
+	if _, err := fmt.Fprintf(s.w, `{"opening_balance":%s,"entries":[`, formatAmount(balance)); err != nil {
+		return fmt.Errorf("failed to write json: %w", err)
+	}
+	return nil
+}
+
+func (s *jsonStatementWriter) WriteEntry(entry models.StatementEntry) error {
+	s.balance += entry.Amount()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal statement entry: %w", err)
+	}
+	if s.count > 0 {
+		data = append([]byte{','}, data...)
+	}
+	s.count++
+
+	if _, err := s.w.Write(data); err != nil {
+		return fmt.Errorf("failed to write json: %w", err)
+	}
+	return nil
+}
+
+func (s *jsonStatementWriter) Close() error {
+	if _, err := fmt.Fprintf(s.w, `],"closing_balance":%s}`, formatAmount(s.balance)); err != nil {
+		return fmt.Errorf("failed to write json: %w", err)
+	}
+	return nil
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
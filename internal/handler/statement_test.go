@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/config"
+	"github.com/tiunovvv/gophermart/internal/database"
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+// statementMart streams one adjustment after delay.
+type statementMart struct {
+	fakeMart
+	delay time.Duration
+}
+
+func (m *statementMart) StreamStatement(
+	ctx context.Context,
+	_ string,
+	_ models.Period,
+	w database.StatementWriter,
+) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(m.delay):
+	}
+
+	if err := w.WriteOpening(100); err != nil {
+		return err
+	}
+	return w.WriteEntry(models.StatementEntry{
+		Type:       models.TransactionAdjustment,
+		Adjustment: &models.Transaction{Type: models.TransactionAdjustment, Amount: -40},
+	})
+}
+
+func TestGetStatement(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		delay       time.Duration
+		wantStatus  int
+		wantClosing float64
+	}{
+		{name: "json", query: "", wantStatus: http.StatusOK, wantClosing: 60},
+		{name: "slower than the handler timeout", query: "", delay: 50 * time.Millisecond,
+			wantStatus: http.StatusOK, wantClosing: 60},
+		{name: "unsupported format", query: "?format=xml", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mart := &statementMart{delay: tt.delay}
+			router, cookie := newTestRouterWithConfig(t, mart, func(cfg *config.Config) {
+				cfg.Server.HandlerTimeout = 10 * time.Millisecond
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/statement"+tt.query, http.NoBody)
+			req.AddCookie(cookie)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var statement struct {
+				Closing float64 `json:"closing_balance"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &statement); err != nil {
+				t.Fatalf("statement is not valid JSON: %v: %s", err, rec.Body)
+			}
+			if statement.Closing != tt.wantClosing {
+				t.Errorf("closing balance = %v, want %v", statement.Closing, tt.wantClosing)
+			}
+		})
+	}
+}
+
+func TestGetStatementExtendsWriteDeadline(t *testing.T) {
+	const writeTimeout = 20 * time.Millisecond
+
+	mart := &statementMart{delay: 5 * writeTimeout}
+	router, cookie := newTestRouterWithConfig(t, mart, func(cfg *config.Config) {
+		cfg.Server.WriteTimeout = writeTimeout
+	})
+	srv := httptest.NewUnstartedServer(router)
+	srv.Config.WriteTimeout = writeTimeout
+	srv.Start()
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/user/statement", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.AddCookie(cookie)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("statement request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var statement struct {
+		Closing float64 `json:"closing_balance"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&statement); err != nil {
+		t.Fatalf("statement was cut off: %v", err)
+	}
+	if statement.Closing != 60 {
+		t.Errorf("closing balance = %v, want 60", statement.Closing)
+	}
+}
@@ -20,16 +20,18 @@ func (h *Handler) SaveWithdraw(c *gin.Context) {
 	var withdraw models.Withdraw
 	if err := c.ShouldBindJSON(&withdraw); err != nil {
 		h.log.Error("failed to decode request JSON body: %w", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		c.AbortWithStatus(bodyErrorStatus(err))
 		return
 	}
+	withdraw.Order = normalizeOrderNumber(withdraw.Order)
 
-	if !h.mart.CheckLunaAlgorithm(withdraw.Order) {
+	if withdraw.Sum <= 0 || !h.mart.CheckOrderNumber(withdraw.Order) {
 		c.AbortWithStatus(http.StatusUnprocessableEntity)
 		return
 	}
 
-	err := h.mart.SaveWithdraw(c, userID, withdraw)
+	// A withdrawal held for review is accepted as well, its status is shown in GetWithdrawals.
+	_, err := h.mart.SaveWithdraw(c, userID, withdraw)
 
 	if errors.Is(err, myErrors.ErrWithdrawAlreadySaved) {
 		c.AbortWithStatus(http.StatusUnprocessableEntity)
@@ -49,6 +51,33 @@ func (h *Handler) SaveWithdraw(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+func (h *Handler) CancelWithdraw(c *gin.Context) {
+	userID := h.getUserID(c)
+	if len(userID) == 0 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	err := h.mart.CancelWithdraw(c, userID, c.Param("number"))
+
+	if errors.Is(err, myErrors.ErrWithdrawNotFound) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, myErrors.ErrWithdrawNotPending) {
+		c.AbortWithStatus(http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
 func (h *Handler) GetWithdrawals(c *gin.Context) {
 	userID := h.getUserID(c)
 	if len(userID) == 0 {
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+func TestSaveWithdraw(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		status     string
+		wantStatus int
+		wantSaved  bool
+	}{
+		{name: "completed", body: `{"order": "2377225624", "sum": 751}`, status: models.WithdrawCompleted,
+			wantStatus: http.StatusOK, wantSaved: true},
+		{name: "held for review", body: `{"order": "2377225624", "sum": 751}`, status: models.WithdrawPending,
+			wantStatus: http.StatusOK, wantSaved: true},
+		{name: "zero sum", body: `{"order": "2377225624", "sum": 0}`,
+			wantStatus: http.StatusUnprocessableEntity},
+		{name: "negative sum", body: `{"order": "2377225624", "sum": -10}`,
+			wantStatus: http.StatusUnprocessableEntity},
+		{name: "wrong checksum", body: `{"order": "2377225625", "sum": 751}`,
+			wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mart := &fakeMart{withdrawStatus: tt.status}
+			router, cookie := newTestRouter(t, mart)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(cookie)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if saved := mart.withdraw != nil; saved != tt.wantSaved {
+				t.Errorf("withdrawal saved: %v, want %v", saved, tt.wantSaved)
+			}
+		})
+	}
+}
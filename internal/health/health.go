@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tiunovvv/gophermart/internal/accrualclient"
+)
+
+const accrualBudget = 2 * time.Second
+
+var (
+	errShuttingDown    = errors.New("shutting down")
+	errDispatcherStale = errors.New("dispatcher is not running")
+	errBreakerOpen     = errors.New("accrual circuit breaker is open")
+)
+
+type Database interface {
+	Ping(ctx context.Context) error
+	CheckMigrations(ctx context.Context) error
+}
+
+type Dispatcher interface {
+	Alive() bool
+	BreakerState() accrualclient.BreakerState
+}
+
+type Checker struct {
+	db             Database
+	disp           Dispatcher
+	client         *http.Client
+	accrualAddress string
+	shuttingDown   atomic.Bool
+}
+
+func NewChecker(db Database, disp Dispatcher, accrualAddress string) *Checker {
+	return &Checker{
+		db:             db,
+		disp:           disp,
+		client:         &http.Client{Timeout: accrualBudget},
+		accrualAddress: accrualAddress,
+	}
+}
+
+// SetShuttingDown makes /readyz fail so that traffic is drained before the server stops.
+func (ch *Checker) SetShuttingDown() {
+	ch.shuttingDown.Store(true)
+}
+
+func (ch *Checker) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "accrual_breaker": ch.disp.BreakerState()})
+}
+
+func (ch *Checker) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz fails when one of the checks fails, the accrual system has to answer within accrualBudget.
+func (ch *Checker) Readyz(c *gin.Context) {
+	checks := ch.checks()
+
+	results := make(map[string]string, len(checks))
+	status := http.StatusOK
+	for name, check := range checks {
+		if err := check(c.Request.Context()); err != nil {
+			results[name] = err.Error()
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		results[name] = "ok"
+	}
+
+	c.JSON(status, gin.H{"checks": results, "accrual_breaker": ch.disp.BreakerState()})
+}
+
+func (ch *Checker) checks() map[string]func(ctx context.Context) error {
+	return map[string]func(ctx context.Context) error{
+		"shutdown": func(context.Context) error {
+			if ch.shuttingDown.Load() {
+				return errShuttingDown
+			}
+			return nil
+		},
+		"database":   ch.db.Ping,
+		"migrations": ch.db.CheckMigrations,
+		"accrual":    ch.checkAccrual,
+		"accrual_breaker": func(context.Context) error {
+			if ch.disp.BreakerState() == accrualclient.BreakerOpen {
+				return errBreakerOpen
+			}
+			return nil
+		},
+		"dispatcher": func(context.Context) error {
+			if !ch.disp.Alive() {
+				return errDispatcherStale
+			}
+			return nil
+		},
+	}
+}
+
+func (ch *Checker) checkAccrual(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ch.accrualAddress, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := ch.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("accrual is unreachable: %w", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		return fmt.Errorf("failed to close body: %w", err)
+	}
+	return nil
+}
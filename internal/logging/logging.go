@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	HTTP    = "http"
+	DB      = "db"
+	Accrual = "accrual"
+)
+
+// Logger builds per-component loggers that share one encoder and sink but have their own levels.
+type Logger struct {
+	mu      sync.Mutex
+	cfg     config.LogConfig
+	encoder zapcore.Encoder
+	sink    zapcore.WriteSyncer
+	root    zap.AtomicLevel
+	levels  map[string]zap.AtomicLevel
+	loggers map[string]*zap.Logger
+}
+
+func NewLogger(cfg config.LogConfig) (*Logger, error) {
+	root, err := zap.ParseAtomicLevel(cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse log level: %w", err)
+	}
+
+	l := &Logger{
+		cfg:     cfg,
+		encoder: newEncoder(cfg.Format),
+		sink:    zapcore.Lock(os.Stderr),
+		root:    root,
+		levels:  make(map[string]zap.AtomicLevel),
+		loggers: make(map[string]*zap.Logger),
+	}
+
+	for component, level := range cfg.ComponentLevels() {
+		lvl := zap.NewAtomicLevelAt(root.Level())
+		if level != "" {
+			if lvl, err = zap.ParseAtomicLevel(level); err != nil {
+				return nil, fmt.Errorf("failed to parse %s log level: %w", component, err)
+			}
+		}
+		l.levels[component] = lvl
+	}
+
+	return l, nil
+}
+
+func newEncoder(format string) zapcore.Encoder {
+	if format == config.LogFormatJSON {
+		encCfg := zap.NewProductionEncoderConfig()
+		encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+		return zapcore.NewJSONEncoder(encCfg)
+	}
+	return zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+}
+
+// Root returns the logger for everything that is not a named component.
+func (l *Logger) Root() *zap.Logger {
+	return l.logger("", l.root)
+}
+
+// Component returns the logger for http, db or accrual. Unknown names share the root level.
+func (l *Logger) Component(name string) *zap.SugaredLogger {
+	level, ok := l.levels[name]
+	if !ok {
+		level = l.root
+	}
+	return l.logger(name, level).Sugar()
+}
+
+func (l *Logger) logger(name string, level zap.AtomicLevel) *zap.Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if logger, ok := l.loggers[name]; ok {
+		return logger
+	}
+
+	core := newRedactCore(zapcore.NewCore(l.encoder.Clone(), l.sink, level))
+	if l.cfg.SamplingInitial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, l.cfg.SamplingInitial, l.cfg.SamplingThereafter)
+	}
+
+	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+	if name != "" {
+		logger = logger.Named(name)
+	}
+	l.loggers[name] = logger
+	return logger
+}
+
+// SetLevel changes the level of one component, or of the root and every component when component is empty.
+func (l *Logger) SetLevel(component string, level string) error {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("failed to parse log level: %w", err)
+	}
+
+	if component == "" {
+		l.root.SetLevel(lvl)
+		for _, atomic := range l.levels {
+			atomic.SetLevel(lvl)
+		}
+		return nil
+	}
+
+	atomic, ok := l.levels[component]
+	if !ok {
+		return fmt.Errorf("unknown log component %q", component)
+	}
+	atomic.SetLevel(lvl)
+	return nil
+}
+
+// Apply sets levels from a reloaded config.
+func (l *Logger) Apply(cfg config.LogConfig) error {
+	if err := l.SetLevel("", cfg.Level); err != nil {
+		return err
+	}
+	for component, level := range cfg.ComponentLevels() {
+		if level == "" {
+			continue
+		}
+		if err := l.SetLevel(component, level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Logger) Levels() map[string]string {
+	levels := map[string]string{"root": l.root.String()}
+	for component, level := range l.levels {
+		levels[component] = level.String()
+	}
+	return levels
+}
+
+func (l *Logger) Sync() {
+	_ = l.sink.Sync()
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const Redacted = "[REDACTED]"
+
+var sensitiveKeys = []string{"password", "pswd", "hash", "token", "secret", "authorization", "cookie"}
+
+// IsSensitive reports whether a field name or SQL fragment refers to sensitive data.
+func IsSensitive(s string) bool {
+	s = strings.ToLower(s)
+	for _, key := range sensitiveKeys {
+		if strings.Contains(s, key) {
+			return true
+		}
+	}
+	return false
+}
+
+type redactCore struct {
+	zapcore.Core
+}
+
+func newRedactCore(core zapcore.Core) zapcore.Core {
+	return &redactCore{core}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{c.Core.With(redact(fields))}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, redact(fields))
+}
+
+func redact(fields []zapcore.Field) []zapcore.Field {
+	var redacted []zapcore.Field
+	for i, field := range fields {
+		if !IsSensitive(field.Key) {
+			continue
+		}
+		if redacted == nil {
+			redacted = make([]zapcore.Field, len(fields))
+			copy(redacted, fields)
+		}
+		redacted[i] = zap.String(field.Key, Redacted)
+	}
+	if redacted == nil {
+		return fields
+	}
+	return redacted
+}
@@ -0,0 +1,158 @@
+package mart
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tiunovvv/gophermart/internal/models"
+
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
+)
+
+const (
+	ActionGetUser            = "get_user"
+	ActionGetUserOrders      = "get_user_orders"
+	ActionGetUserWithdrawals = "get_user_withdrawals"
+	ActionResetOrder         = "reset_order"
+	ActionInvalidateOrder    = "invalidate_order"
+	ActionLockUser           = "lock_user"
+	ActionUnlockUser         = "unlock_user"
+	ActionSetLogLevel        = "set_log_level"
+	ActionAdjustBalance      = "adjust_balance"
+	ActionRefundWithdraw     = "refund_withdraw"
+	ActionClawbackOrder      = "clawback_order"
+	ActionApproveWithdraw    = "approve_withdraw"
+	ActionRejectWithdraw     = "reject_withdraw"
+)
+
+func (m *Mart) Audit(ctx context.Context, operator string, action string, target string, details string) error {
+	entry := models.AuditEntry{Operator: operator, Action: action, Target: target, Details: details}
+	if err := m.db.Audit(ctx, entry); err != nil {
+		m.log.Errorf("failed to write audit entry: %v", err)
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+func (m *Mart) FindUser(ctx context.Context, operator string, login string) (models.UserInfo, error) {
+	user, err := m.db.GetUserByLogin(ctx, login)
+	if err != nil {
+		return user, fmt.Errorf("failed to find user: %w", err)
+	}
+	if err := m.Audit(ctx, operator, ActionGetUser, login, ""); err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+func (m *Mart) FindUserOrders(ctx context.Context, operator string, login string) ([]models.OrderWithTime, error) {
+	user, err := m.db.GetUserByLogin(ctx, login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if err := m.Audit(ctx, operator, ActionGetUserOrders, login, ""); err != nil {
+		return nil, err
+	}
+	return m.GetOrdersForUser(ctx, user.UserID)
+}
+
+func (m *Mart) FindUserWithdrawals(ctx context.Context, operator string, login string) ([]models.Withdrawals, error) {
+	user, err := m.db.GetUserByLogin(ctx, login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if err := m.Audit(ctx, operator, ActionGetUserWithdrawals, login, ""); err != nil {
+		return nil, err
+	}
+	return m.GetWindrawalsForUser(ctx, user.UserID)
+}
+
+func (m *Mart) ResetOrder(ctx context.Context, operator string, number string) error {
+	entry := models.AuditEntry{Operator: operator, Action: ActionResetOrder, Target: number}
+	if err := m.db.SetOrderStatus(ctx, number, models.OrderNew, entry); err != nil {
+		m.log.Errorf("failed to reset order: %v", err)
+		return fmt.Errorf("failed to reset order: %w", err)
+	}
+	return nil
+}
+
+func (m *Mart) InvalidateOrder(ctx context.Context, operator string, number string) error {
+	entry := models.AuditEntry{Operator: operator, Action: ActionInvalidateOrder, Target: number}
+	if err := m.db.InvalidateOrder(ctx, number, entry); err != nil {
+		m.log.Errorf("failed to invalidate order: %v", err)
+		return fmt.Errorf("failed to invalidate order: %w", err)
+	}
+	return nil
+}
+
+func (m *Mart) SetUserLocked(ctx context.Context, operator string, login string, locked bool) error {
+	action := ActionUnlockUser
+	if locked {
+		action = ActionLockUser
+	}
+	entry := models.AuditEntry{Operator: operator, Action: action, Target: login}
+	if err := m.db.SetUserLocked(ctx, login, locked, entry); err != nil {
+		m.log.Errorf("failed to %s: %v", action, err)
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	return nil
+}
+
+func (m *Mart) IsUserLocked(ctx context.Context, userID string) (bool, error) {
+	locked, err := m.db.IsUserLocked(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check user lock: %w", err)
+	}
+	return locked, nil
+}
+
+func (m *Mart) SaveAdjustment(ctx context.Context, operator string, login string, adjustment models.Adjustment) error {
+	if adjustment.Amount == 0 ||
+		(adjustment.Reason != models.ReasonGoodwill && adjustment.Reason != models.ReasonCorrection) {
+		return myErrors.ErrInvalidAdjustment
+	}
+
+	details := fmt.Sprintf("amount=%v reason=%s reference=%q", adjustment.Amount, adjustment.Reason, adjustment.Reference)
+	entry := models.AuditEntry{Operator: operator, Action: ActionAdjustBalance, Target: login, Details: details}
+	if err := m.db.SaveAdjustment(ctx, login, adjustment, entry); err != nil {
+		m.log.Errorf("failed to save adjustment: %v", err)
+		return fmt.Errorf("failed to save adjustment: %w", err)
+	}
+	return nil
+}
+
+func (m *Mart) RefundWithdraw(ctx context.Context, operator string, number string) error {
+	entry := models.AuditEntry{Operator: operator, Action: ActionRefundWithdraw, Target: number}
+	if err := m.db.RefundWithdraw(ctx, number, entry); err != nil {
+		m.log.Errorf("failed to refund withdraw: %v", err)
+		return fmt.Errorf("failed to refund withdraw: %w", err)
+	}
+	return nil
+}
+
+func (m *Mart) ClawbackOrder(ctx context.Context, operator string, number string) error {
+	entry := models.AuditEntry{Operator: operator, Action: ActionClawbackOrder, Target: number}
+	if err := m.db.ClawbackOrder(ctx, number, entry); err != nil {
+		m.log.Errorf("failed to claw back order: %v", err)
+		return fmt.Errorf("failed to claw back order: %w", err)
+	}
+	return nil
+}
+
+func (m *Mart) ApproveWithdraw(ctx context.Context, operator string, number string) error {
+	entry := models.AuditEntry{Operator: operator, Action: ActionApproveWithdraw, Target: number}
+	if err := m.db.ResolveWithdraw(ctx, number, "", models.WithdrawCompleted, &entry); err != nil {
+		m.log.Errorf("failed to approve withdraw: %v", err)
+		return fmt.Errorf("failed to approve withdraw: %w", err)
+	}
+	return nil
+}
+
+func (m *Mart) RejectWithdraw(ctx context.Context, operator string, number string) error {
+	entry := models.AuditEntry{Operator: operator, Action: ActionRejectWithdraw, Target: number}
+	if err := m.db.ResolveWithdraw(ctx, number, "", models.WithdrawRejected, &entry); err != nil {
+		m.log.Errorf("failed to reject withdraw: %v", err)
+		return fmt.Errorf("failed to reject withdraw: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package mart
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tiunovvv/gophermart/internal/models"
+)
+
+const (
+	// idempotencyTTL is how long a stored response is replayed for a repeated Idempotency-Key.
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyLeaseMargin is added to the handler timeout to get how long a request holds its key
+	// before a retry may take it over.
+	idempotencyLeaseMargin = 5 * time.Second
+)
+
+func (m *Mart) ReserveIdempotencyKey(
+	ctx context.Context,
+	userID string,
+	key string,
+	requestHash string,
+) (*models.IdempotentResponse, error) {
+	now := time.Now()
+	lockedUntil := now.Add(m.cfg.Server.HandlerTimeout + idempotencyLeaseMargin)
+	response, err := m.db.ReserveIdempotencyKey(ctx, userID, key, requestHash, lockedUntil, now.Add(-idempotencyTTL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	return response, nil
+}
+
+func (m *Mart) SaveIdempotentResponse(
+	ctx context.Context,
+	userID string,
+	key string,
+	response models.IdempotentResponse,
+) error {
+	if err := m.db.SaveIdempotentResponse(ctx, userID, key, response); err != nil {
+		m.log.Errorf("failed to save idempotent response: %v", err)
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (m *Mart) ReleaseIdempotencyKey(ctx context.Context, userID string, key string) error {
+	if err := m.db.ReleaseIdempotencyKey(ctx, userID, key); err != nil {
+		m.log.Errorf("failed to release idempotency key: %v", err)
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
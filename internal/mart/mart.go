@@ -2,25 +2,44 @@ package mart
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/tiunovvv/gophermart/internal/config"
 	"github.com/tiunovvv/gophermart/internal/database"
+	myErrors "github.com/tiunovvv/gophermart/internal/errors"
 	"github.com/tiunovvv/gophermart/internal/models"
+	"github.com/tiunovvv/gophermart/internal/ordernum"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Mart struct {
-	db  *database.DB
-	log *zap.SugaredLogger
+	cfg       *config.Config
+	db        *database.DB
+	log       *zap.SugaredLogger
+	validator ordernum.Validator
 }
 
-func NewMart(db *database.DB, log *zap.SugaredLogger) *Mart {
-	return &Mart{
-		db:  db,
-		log: log,
+func NewMart(cfg *config.Config, db *database.DB, log *zap.SugaredLogger) (*Mart, error) {
+	validator, err := cfg.Orders.Validator()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order number validator: %w", err)
 	}
+
+	return &Mart{
+		cfg:       cfg,
+		db:        db,
+		log:       log,
+		validator: validator,
+	}, nil
+}
+
+// CheckOrderNumber validates an order number with the configured check-digit scheme.
+func (m *Mart) CheckOrderNumber(number string) bool {
+	return m.validator.Valid(number)
 }
 
 func (m *Mart) NewUser(ctx context.Context, user models.User) (string, error) {
@@ -31,8 +50,7 @@ func (m *Mart) NewUser(ctx context.Context, user models.User) (string, error) {
 
 	userID := uuid.String()
 
-	const length = 10
-	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), length)
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), m.cfg.Auth.BcryptCost)
 	if err != nil {
 		return "", fmt.Errorf("failed to create password hash: %w", err)
 	}
@@ -54,11 +72,21 @@ func (m *Mart) GetUserID(ctx context.Context, user models.User) (string, error)
 		return "", fmt.Errorf("failed to check password: %w", err)
 	}
 
+	locked, err := m.db.IsUserLocked(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to check user lock: %w", err)
+	}
+	if locked {
+		return "", myErrors.ErrUserLocked
+	}
+
 	return userID, nil
 }
 
-func (m *Mart) SaveOrder(ctx context.Context, userID string, number string) error {
-	err := m.db.SaveOrder(ctx, userID, number)
+// SaveOrder uploads an order. Orders with goods, even an empty list, are registered in the accrual
+// system by the dispatcher before they are polled.
+func (m *Mart) SaveOrder(ctx context.Context, userID string, number string, goods []models.Good) error {
+	err := m.db.SaveOrder(ctx, userID, number, goods)
 	if err != nil {
 		m.log.Errorf("failed to save order: %v", err)
 		return fmt.Errorf("failed to save order: %w", err)
@@ -66,8 +94,55 @@ func (m *Mart) SaveOrder(ctx context.Context, userID string, number string) erro
 	return nil
 }
 
-func (m *Mart) GetNewOrders(ctx context.Context) ([]models.OrderWithTime, error) {
-	orders, err := m.db.GetNewOrders(ctx)
+// SaveOrders uploads a batch of order numbers and reports the result for each one in input order.
+// Repeated numbers within the batch are reported as already uploaded.
+func (m *Mart) SaveOrders(ctx context.Context, userID string, numbers []string) ([]models.UploadResult, error) {
+	results := make([]models.UploadResult, len(numbers))
+	seen := make(map[string]bool, len(numbers))
+	repeated := make([]bool, len(numbers))
+	valid := make([]string, 0, len(numbers))
+	for i, number := range numbers {
+		results[i].Number = number
+		switch {
+		case !m.CheckOrderNumber(number):
+			results[i].Result = models.UploadInvalid
+		case seen[number]:
+			repeated[i] = true
+		default:
+			seen[number] = true
+			valid = append(valid, number)
+		}
+	}
+
+	var owners map[string]string
+	if len(valid) > 0 {
+		var err error
+		owners, err = m.db.SaveOrders(ctx, userID, valid)
+		if err != nil {
+			m.log.Errorf("failed to save orders: %v", err)
+			return nil, fmt.Errorf("failed to save orders: %w", err)
+		}
+	}
+
+	for i := range results {
+		if results[i].Result != "" {
+			continue
+		}
+		owner, exists := owners[results[i].Number]
+		switch {
+		case !exists && !repeated[i]:
+			results[i].Result = models.UploadAccepted
+		case !exists || owner == userID:
+			results[i].Result = models.UploadAlreadyUploaded
+		default:
+			results[i].Result = models.UploadOwnedByOther
+		}
+	}
+	return results, nil
+}
+
+func (m *Mart) GetNewOrders(ctx context.Context, exclude []string) ([]models.OrderWithTime, error) {
+	orders, err := m.db.GetNewOrders(ctx, m.cfg.Accrual.BatchSize, exclude, m.pollBefore())
 	if err != nil {
 		m.log.Errorf("failed to get new orders: %v", err)
 		return nil, fmt.Errorf("failed to get new orders: %w", err)
@@ -75,6 +150,54 @@ func (m *Mart) GetNewOrders(ctx context.Context) ([]models.OrderWithTime, error)
 	return orders, nil
 }
 
+// pollBefore returns the time since which an order must not have been heard of to be polled. With
+// accrual callbacks enabled, polling is the fallback for orders that got no callback within the callback
+// timeout of their upload or of their last callback.
+func (m *Mart) pollBefore() time.Time {
+	if m.cfg.Accrual.CallbackSecret == "" {
+		return time.Now()
+	}
+	return time.Now().Add(-m.cfg.Accrual.CallbackTimeout)
+}
+
+// ListenNewOrders signals wake when new orders may be waiting for the accrual, see database.ListenNewOrders.
+func (m *Mart) ListenNewOrders(ctx context.Context, wake chan<- struct{}) {
+	m.db.ListenNewOrders(ctx, wake)
+}
+
+func (m *Mart) GetDueRegistrations(ctx context.Context) ([]models.Registration, error) {
+	registrations, err := m.db.GetDueRegistrations(ctx, m.cfg.Accrual.BatchSize, time.Now())
+	if err != nil {
+		m.log.Errorf("failed to get due registrations: %v", err)
+		return nil, fmt.Errorf("failed to get due registrations: %w", err)
+	}
+	return registrations, nil
+}
+
+func (m *Mart) SetRegistration(ctx context.Context, number string, state string) error {
+	if err := m.db.SetRegistration(ctx, number, state); err != nil {
+		m.log.Errorf("failed to set registration: %v", err)
+		return fmt.Errorf("failed to set registration: %w", err)
+	}
+	return nil
+}
+
+func (m *Mart) DelayRegistration(ctx context.Context, number string, attempts int, nextAt time.Time) error {
+	if err := m.db.DelayRegistration(ctx, number, attempts, nextAt); err != nil {
+		m.log.Errorf("failed to delay registration: %v", err)
+		return fmt.Errorf("failed to delay registration: %w", err)
+	}
+	return nil
+}
+
+func (m *Mart) CountDueOrders(ctx context.Context) (int64, error) {
+	count, err := m.db.CountDueOrders(ctx, m.pollBefore())
+	if err != nil {
+		return 0, fmt.Errorf("failed to count due orders: %w", err)
+	}
+	return count, nil
+}
+
 func (m *Mart) GetOrdersForUser(ctx context.Context, userID string) ([]models.OrderWithTime, error) {
 	orders, err := m.db.GetOrdersForUser(ctx, userID)
 	if err != nil {
@@ -84,8 +207,16 @@ func (m *Mart) GetOrdersForUser(ctx context.Context, userID string) ([]models.Or
 	return orders, nil
 }
 
+func (m *Mart) GetOrderForUser(ctx context.Context, userID string, number string) (models.OrderDetails, error) {
+	details, err := m.db.GetOrderForUser(ctx, userID, number)
+	if err != nil {
+		return details, fmt.Errorf("failed to get order for user: %w", err)
+	}
+	return details, nil
+}
+
 func (m *Mart) GetBalance(ctx context.Context, userID string) (models.Balance, error) {
-	balance, err := m.db.Getbalance(ctx, userID)
+	balance, err := m.db.Getbalance(ctx, userID, time.Now().Add(m.cfg.Expiration.WarnWindow))
 	if err != nil {
 		m.log.Errorf("failed to get balance for user: %v", err)
 		return balance, fmt.Errorf("failed to get balance for user: %w", err)
@@ -93,11 +224,27 @@ func (m *Mart) GetBalance(ctx context.Context, userID string) (models.Balance, e
 	return balance, nil
 }
 
-func (m *Mart) SaveWithdraw(ctx context.Context, userID string, withdraw models.Withdraw) error {
-	err := m.db.SaveWithdraw(ctx, userID, withdraw)
+// SaveWithdraw returns the status of the new withdrawal: COMPLETED, or PENDING when the sum
+// reaches the review threshold.
+func (m *Mart) SaveWithdraw(ctx context.Context, userID string, withdraw models.Withdraw) (string, error) {
+	status := models.WithdrawCompleted
+	if threshold := m.cfg.Withdraw.ReviewThreshold; threshold > 0 && withdraw.Sum >= threshold {
+		status = models.WithdrawPending
+	}
+
+	err := m.db.SaveWithdraw(ctx, userID, withdraw, status)
 	if err != nil {
 		m.log.Errorf("failed to save withdraw: %v", err)
-		return fmt.Errorf("failed to save withdraw: %w", err)
+		return "", fmt.Errorf("failed to save withdraw: %w", err)
+	}
+	return status, nil
+}
+
+func (m *Mart) CancelWithdraw(ctx context.Context, userID string, number string) error {
+	err := m.db.ResolveWithdraw(ctx, number, userID, models.WithdrawCanceled, nil)
+	if err != nil {
+		m.log.Errorf("failed to cancel withdraw: %v", err)
+		return fmt.Errorf("failed to cancel withdraw: %w", err)
 	}
 	return nil
 }
@@ -111,11 +258,76 @@ func (m *Mart) GetWindrawalsForUser(ctx context.Context, userID string) ([]model
 	return withdrawals, nil
 }
 
-func (m *Mart) UpdateOrderAccrual(ctx context.Context, order models.Order) error {
-	err := m.db.UpdateOrderAccrual(ctx, order)
+// ApplyAccrualCallback applies an order status pushed by the accrual system.
+func (m *Mart) ApplyAccrualCallback(ctx context.Context, order models.Order) error {
+	err := m.db.ApplyAccrualCallback(ctx, order, m.lotExpiry())
+	if errors.Is(err, myErrors.ErrIllegalTransition) {
+		m.log.Warnw("rejected order status update", "order", order.Order, "error", err)
+		return fmt.Errorf("failed to update order: %w", err)
+	}
 	if err != nil {
 		m.log.Errorf("failed to update order: %v", err)
 		return fmt.Errorf("failed to update order: %w", err)
 	}
 	return nil
 }
+
+// UpdateOrdersAccrual stores a batch of accrual results. Rejected results are logged and dropped,
+// an error means that none of the results was stored.
+func (m *Mart) UpdateOrdersAccrual(ctx context.Context, orders []models.Order) error {
+	rejected, err := m.db.UpdateOrdersAccrual(ctx, orders, m.lotExpiry())
+	if err != nil {
+		m.log.Errorf("failed to update orders: %v", err)
+		return fmt.Errorf("failed to update orders: %w", err)
+	}
+	for number, err := range rejected {
+		m.log.Warnw("rejected order status update", "order", number, "error", err)
+	}
+	return nil
+}
+
+// lotExpiry returns when points accrued now expire, nil if they never do.
+func (m *Mart) lotExpiry() *time.Time {
+	if !m.cfg.Expiration.Enabled {
+		return nil
+	}
+	at := time.Now().AddDate(0, m.cfg.Expiration.Months, 0)
+	return &at
+}
+
+// ExpirePoints writes off every lot that expired by now and returns the number of expired lots.
+func (m *Mart) ExpirePoints(ctx context.Context, now time.Time) (int64, error) {
+	expired, err := m.db.ExpireLots(ctx, now)
+	if err != nil {
+		m.log.Errorf("failed to expire points: %v", err)
+		return 0, fmt.Errorf("failed to expire points: %w", err)
+	}
+	return expired, nil
+}
+
+func (m *Mart) GetTransactionsForUser(
+	ctx context.Context,
+	userID string,
+	period models.Period,
+	page models.Page,
+) ([]models.Transaction, error) {
+	transactions, err := m.db.GetTransactionsForUser(ctx, userID, period, page)
+	if err != nil {
+		m.log.Errorf("failed to get transactions: %v", err)
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+	return transactions, nil
+}
+
+func (m *Mart) StreamStatement(
+	ctx context.Context,
+	userID string,
+	period models.Period,
+	w database.StatementWriter,
+) error {
+	if err := m.db.StreamStatement(ctx, userID, period, w); err != nil {
+		m.log.Errorf("failed to stream statement: %v", err)
+		return fmt.Errorf("failed to stream statement: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "gophermart"
+
+type Metrics struct {
+	Registry        *prometheus.Registry
+	HTTPDuration    *prometheus.HistogramVec
+	AccrualDuration *prometheus.HistogramVec
+	AccrualRequests *prometheus.CounterVec
+	WorkersActive   prometheus.Gauge
+	AccrualScaling  *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	m := &Metrics{
+		Registry: registry,
+		HTTPDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of HTTP requests by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		AccrualDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "accrual",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of requests to the accrual system by outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		AccrualRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "accrual",
+			Name:      "requests_total",
+			Help:      "Requests to the accrual system by outcome (200, 204, 429, 500, error).",
+		}, []string{"outcome"}),
+		WorkersActive: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "accrual",
+			Name:      "workers_active",
+			Help:      "Number of live accrual workers.",
+		}),
+		AccrualScaling: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "accrual",
+			Name:      "scaling_decisions_total",
+			Help:      "Accrual worker pool resizes by direction (up, down) and reason (backlog, idle, rate_limited).",
+		}, []string{"direction", "reason"}),
+	}
+
+	registry.MustRegister(m.HTTPDuration, m.AccrualDuration, m.AccrualRequests, m.WorkersActive, m.AccrualScaling)
+
+	return m
+}
+
+// MustRegister registers collectors owned by other components, e.g. the DB pool or the dispatcher queue.
+func (m *Metrics) MustRegister(cs ...prometheus.Collector) {
+	m.Registry.MustRegister(cs...)
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireContentType answers 415 to requests whose media type is not one of types.
+// Parameters such as charset are ignored.
+func RequireContentType(types ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(types, c.ContentType()) {
+			c.AbortWithStatus(http.StatusUnsupportedMediaType)
+			return
+		}
+		c.Next()
+	}
+}
+
+// LimitBody answers 413 to requests that declare a body larger than limit bytes and caps
+// reading of the others, so that handlers get *http.MaxBytesError past the limit.
+func LimitBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
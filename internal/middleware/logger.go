@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"net/http"
 	"strconv"
 	"time"
 
@@ -17,7 +18,15 @@ type bodyLogWriter struct {
 func (w *bodyLogWriter) Write(b []byte) (int, error) {
 	size, err := w.ResponseWriter.Write(b)
 	w.size += size
-	return size, fmt.Errorf("failed to calculate size: %w", err)
+	if err != nil {
+		return size, fmt.Errorf("failed to write response: %w", err)
+	}
+	return size, nil
+}
+
+// Unwrap lets http.ResponseController reach the connection, streaming handlers move its write deadline.
+func (w *bodyLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
 func GinLogger(log *zap.SugaredLogger) gin.HandlerFunc {
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tiunovvv/gophermart/internal/metrics"
+)
+
+func GinMetrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		m.HTTPDuration.WithLabelValues(
+			route,
+			c.Request.Method,
+			strconv.Itoa(c.Writer.Status()),
+		).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// SignatureHeader carries the hex HMAC-SHA256 of the timestamp and the body, prefixed with "sha256=".
+	SignatureHeader = "X-Accrual-Signature"
+	// TimestampHeader carries the Unix time in seconds when the request was signed.
+	TimestampHeader = "X-Accrual-Timestamp"
+	// SignatureTolerance is how far the signing time may be from now, so that a captured request
+	// cannot be replayed later.
+	SignatureTolerance = 5 * time.Minute
+)
+
+const signaturePrefix = "sha256="
+
+// RequireSignature answers 401 to requests whose timestamp and body are not signed with secret in
+// SignatureHeader or were signed more than SignatureTolerance away from now. The body is restored
+// for the handler.
+func RequireSignature(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		signature, ok := strings.CutPrefix(c.GetHeader(SignatureHeader), signaturePrefix)
+		if !ok {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		want, err := hex.DecodeString(signature)
+		if err != nil {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		timestamp := c.GetHeader(TimestampHeader)
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if age := time.Since(time.Unix(seconds, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+				return
+			}
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if !hmac.Equal(Sign(secret, timestamp, body), want) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Sign returns the HMAC-SHA256 of timestamp, a dot and body. The accrual system sends it hex encoded
+// in SignatureHeader and timestamp in TimestampHeader.
+func Sign(secret string, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireSignature(t *testing.T) {
+	const (
+		secret = "secret"
+		body   = `{"order": "12345678903", "status": "PROCESSED", "accrual": 500}`
+	)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-SignatureTolerance-time.Minute).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(SignatureTolerance+time.Minute).Unix(), 10)
+	sign := func(secret, timestamp, body string) string {
+		return signaturePrefix + hex.EncodeToString(Sign(secret, timestamp, []byte(body)))
+	}
+
+	tests := []struct {
+		name       string
+		timestamp  string
+		signature  string
+		body       string
+		wantStatus int
+	}{
+		{name: "valid", timestamp: now, signature: sign(secret, now, body), body: body, wantStatus: http.StatusOK},
+		{name: "wrong secret", timestamp: now, signature: sign("other", now, body), body: body,
+			wantStatus: http.StatusUnauthorized},
+		{name: "tampered body", timestamp: now, signature: sign(secret, now, body), body: body + " ",
+			wantStatus: http.StatusUnauthorized},
+		{name: "timestamp not signed", timestamp: now, signature: signaturePrefix +
+			hex.EncodeToString(Sign(secret, "", []byte(body))), body: body, wantStatus: http.StatusUnauthorized},
+		{name: "missing timestamp", signature: sign(secret, "", body), body: body,
+			wantStatus: http.StatusUnauthorized},
+		{name: "stale timestamp", timestamp: stale, signature: sign(secret, stale, body), body: body,
+			wantStatus: http.StatusUnauthorized},
+		{name: "future timestamp", timestamp: future, signature: sign(secret, future, body), body: body,
+			wantStatus: http.StatusUnauthorized},
+		{name: "missing prefix", timestamp: now, signature: strings.TrimPrefix(sign(secret, now, body), signaturePrefix),
+			body: body, wantStatus: http.StatusUnauthorized},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/callback", RequireSignature(secret), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(tt.body))
+			req.Header.Set(SignatureHeader, tt.signature)
+			if tt.timestamp != "" {
+				req.Header.Set(TimestampHeader, tt.timestamp)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
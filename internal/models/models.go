@@ -8,30 +8,170 @@ type User struct {
 }
 
 type Order struct {
-	Order   string  `json:"order"`
-	Status  string  `json:"status"`
-	Accrual float64 `json:"accrual"`
+	Order   string      `json:"order"`
+	Status  OrderStatus `json:"status"`
+	Accrual float64     `json:"accrual"`
 }
 
 type OrderWithTime struct {
 	UploadedAt time.Time `json:"uploaded_at"`
-	Number     string    `json:"number"`
-	Status     string    `json:"status"`
-	Accrual    float64   `json:"accrual,omitempty"`
+	// ProcessedAt is set for accruals of a statement, which are dated by it.
+	ProcessedAt *time.Time  `json:"processed_at,omitempty"`
+	Number      string      `json:"number"`
+	Status      OrderStatus `json:"status"`
+	Accrual     float64     `json:"accrual,omitempty"`
+}
+
+// Good is an item of an order, the accrual system rewards it by its description.
+type Good struct {
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+}
+
+// OrderWithGoods is the JSON body of an order upload. Its goods are registered in the accrual system.
+type OrderWithGoods struct {
+	Order string `json:"order"`
+	Goods []Good `json:"goods"`
+}
+
+// Registration states of an order uploaded with goods. Orders are polled only once REGISTERED.
+const (
+	RegistrationPending    = "PENDING"
+	RegistrationRegistered = "REGISTERED"
+	RegistrationFailed     = "FAILED"
+)
+
+// Registration is an order waiting to be registered in the accrual system.
+type Registration struct {
+	Number   string
+	Goods    []Good
+	Attempts int
+}
+
+const (
+	UploadAccepted        = "ACCEPTED"
+	UploadAlreadyUploaded = "ALREADY_UPLOADED"
+	UploadOwnedByOther    = "OWNED_BY_OTHER_USER"
+	UploadInvalid         = "INVALID"
+)
+
+// UploadResult is the outcome for one number of a batch order upload.
+type UploadResult struct {
+	Number string `json:"number"`
+	Result string `json:"result"`
 }
 
 type Balance struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current      float64 `json:"current"`
+	Withdrawn    float64 `json:"withdrawn"`
+	ExpiringSoon float64 `json:"expiring_soon"`
+	// Held is reserved by withdrawals waiting for review and already excluded from Current.
+	Held float64 `json:"held"`
 }
 
+const (
+	WithdrawPending   = "PENDING"
+	WithdrawCompleted = "COMPLETED"
+	WithdrawRejected  = "REJECTED"
+	WithdrawCanceled  = "CANCELED"
+)
+
 type Withdraw struct {
 	Order string  `json:"order"`
 	Sum   float64 `json:"sum"`
 }
 
+const (
+	ReasonGoodwill           = "GOODWILL"
+	ReasonCorrection         = "CORRECTION"
+	ReasonWithdrawalReversal = "WITHDRAWAL_REVERSAL"
+	ReasonAccrualClawback    = "ACCRUAL_CLAWBACK"
+	ReasonExpiration         = "EXPIRATION"
+
+	OperatorSystem = "system"
+)
+
+type Adjustment struct {
+	Reason    string  `json:"reason"`
+	Reference string  `json:"reference"`
+	Amount    float64 `json:"amount"`
+}
+
+const (
+	TransactionAccrual    = "ACCRUAL"
+	TransactionWithdrawal = "WITHDRAWAL"
+	TransactionAdjustment = "ADJUSTMENT"
+)
+
+type Transaction struct {
+	CreatedAt time.Time `json:"created_at"`
+	Type      string    `json:"type"`
+	Reference string    `json:"reference,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Amount    float64   `json:"amount"`
+	Balance   float64   `json:"balance"`
+}
+
+// StatementEntry holds exactly one of the entry kinds, Type tells which.
+type StatementEntry struct {
+	Accrual    *OrderWithTime `json:"accrual,omitempty"`
+	Withdrawal *Withdrawals   `json:"withdrawal,omitempty"`
+	Adjustment *Transaction   `json:"adjustment,omitempty"`
+	Type       string         `json:"type"`
+}
+
+// Amount is the signed change of the balance made by the entry.
+func (e StatementEntry) Amount() float64 {
+	switch {
+	case e.Accrual != nil:
+		return e.Accrual.Accrual
+	case e.Withdrawal != nil:
+		return -e.Withdrawal.Sum
+	case e.Adjustment != nil:
+		return e.Adjustment.Amount
+	}
+	return 0
+}
+
+// Period is a half-open [From, To) time range, nil bounds are open.
+type Period struct {
+	From *time.Time
+	To   *time.Time
+}
+
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+type UserInfo struct {
+	UserID string `json:"user_id"`
+	Login  string `json:"login"`
+	Locked bool   `json:"locked"`
+}
+
+type AuditEntry struct {
+	Operator string
+	Action   string
+	Target   string
+	Details  string
+}
+
+type LogLevel struct {
+	Component string `json:"component"`
+	Level     string `json:"level" binding:"required"`
+}
+
 type Withdrawals struct {
 	ProcessedAt time.Time `json:"processed_at"`
 	Order       string    `json:"order"`
+	Status      string    `json:"status"`
 	Sum         float64   `json:"sum"`
 }
+
+// IdempotentResponse is the stored first response to a request with an Idempotency-Key.
+type IdempotentResponse struct {
+	ContentType string
+	Body        []byte
+	Status      int
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// OrderStatus is the gophermart status of an uploaded order.
+type OrderStatus string
+
+const (
+	OrderNew        OrderStatus = "NEW"
+	OrderProcessing OrderStatus = "PROCESSING"
+	OrderInvalid    OrderStatus = "INVALID"
+	OrderProcessed  OrderStatus = "PROCESSED"
+)
+
+// orderTransitions lists the statuses reachable from each status. INVALID and PROCESSED are final.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderNew:        {OrderProcessing, OrderInvalid, OrderProcessed},
+	OrderProcessing: {OrderProcessing, OrderInvalid, OrderProcessed},
+	OrderInvalid:    nil,
+	OrderProcessed:  nil,
+}
+
+// CanTransitionTo reports whether an order in status s may move to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+func (s OrderStatus) Final() bool {
+	return s == OrderInvalid || s == OrderProcessed
+}
+
+const (
+	HistorySourceUpload  = "upload"
+	HistorySourceAccrual = "accrual"
+	HistorySourceAdmin   = "admin"
+	// HistorySourceCallback marks statuses pushed by the accrual system, HistorySourceAccrual polled ones.
+	HistorySourceCallback = "callback"
+	// HistorySourceRegistration marks orders invalidated because the accrual system never registered them.
+	HistorySourceRegistration = "registration"
+)
+
+// OrderStatusChange is one entry of an order timeline. OldStatus is empty for the upload.
+type OrderStatusChange struct {
+	ChangedAt time.Time   `json:"changed_at"`
+	Accrual   *float64    `json:"accrual,omitempty"`
+	OldStatus OrderStatus `json:"old_status,omitempty"`
+	Status    OrderStatus `json:"status"`
+	Source    string      `json:"source"`
+}
+
+type OrderDetails struct {
+	OrderWithTime
+	// Registration is set for orders uploaded with goods, see RegistrationPending.
+	Registration string              `json:"registration,omitempty"`
+	History      []OrderStatusChange `json:"history"`
+}
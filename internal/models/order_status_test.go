@@ -0,0 +1,50 @@
+package models
+
+import "testing"
+
+func TestOrderStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from OrderStatus
+		to   OrderStatus
+		want bool
+	}{
+		{from: OrderNew, to: OrderNew},
+		{from: OrderNew, to: OrderProcessing, want: true},
+		{from: OrderNew, to: OrderInvalid, want: true},
+		{from: OrderNew, to: OrderProcessed, want: true},
+
+		{from: OrderProcessing, to: OrderNew},
+		{from: OrderProcessing, to: OrderProcessing, want: true},
+		{from: OrderProcessing, to: OrderInvalid, want: true},
+		{from: OrderProcessing, to: OrderProcessed, want: true},
+
+		{from: OrderInvalid, to: OrderNew},
+		{from: OrderInvalid, to: OrderProcessing},
+		{from: OrderInvalid, to: OrderInvalid},
+		{from: OrderInvalid, to: OrderProcessed},
+
+		{from: OrderProcessed, to: OrderNew},
+		{from: OrderProcessed, to: OrderProcessing},
+		{from: OrderProcessed, to: OrderInvalid},
+		{from: OrderProcessed, to: OrderProcessed},
+
+		{from: "UNKNOWN", to: OrderProcessing},
+		{from: OrderNew, to: "UNKNOWN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderStatusFinal(t *testing.T) {
+	for status, next := range orderTransitions {
+		if got, want := status.Final(), len(next) == 0; got != want {
+			t.Errorf("%s.Final() = %v, but it has transitions %v", status, got, next)
+		}
+	}
+}
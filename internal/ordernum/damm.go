@@ -0,0 +1,32 @@
+package ordernum
+
+// dammQuasigroup is the totally anti-symmetric quasigroup of order 10 from Damm's thesis.
+var dammQuasigroup = [10][10]uint8{
+	{0, 3, 1, 7, 5, 9, 8, 6, 4, 2},
+	{7, 0, 9, 2, 1, 5, 4, 8, 6, 3},
+	{4, 2, 0, 6, 8, 7, 1, 3, 5, 9},
+	{1, 7, 5, 0, 9, 8, 3, 4, 2, 6},
+	{6, 1, 2, 3, 0, 4, 5, 9, 7, 8},
+	{3, 6, 7, 4, 2, 0, 9, 5, 8, 1},
+	{5, 8, 6, 9, 7, 2, 0, 1, 3, 4},
+	{8, 9, 4, 5, 3, 6, 2, 0, 1, 7},
+	{9, 4, 3, 8, 6, 1, 7, 2, 0, 5},
+	{2, 5, 8, 1, 4, 3, 6, 7, 9, 0},
+}
+
+// Damm detects all single-digit errors and all transpositions of adjacent digits with a single table.
+type Damm struct {
+	Bounds
+}
+
+func (v Damm) Valid(number string) bool {
+	if !v.digits(number) {
+		return false
+	}
+
+	var interim uint8
+	for i := 0; i < len(number); i++ {
+		interim = dammQuasigroup[interim][number[i]-'0']
+	}
+	return interim == 0
+}
@@ -0,0 +1,20 @@
+package ordernum
+
+// ISO7064 is the numeric MOD 97-10 scheme of ISO/IEC 7064 with two trailing check digits,
+// as used by IBAN.
+type ISO7064 struct {
+	Bounds
+}
+
+func (v ISO7064) Valid(number string) bool {
+	const modulus = 97
+	if len(number) < 3 || !v.digits(number) {
+		return false
+	}
+
+	remainder := 0
+	for i := 0; i < len(number); i++ {
+		remainder = (remainder*10 + int(number[i]-'0')) % modulus
+	}
+	return remainder == 1
+}
@@ -0,0 +1,27 @@
+package ordernum
+
+// Luhn is the mod 10 scheme used by payment card numbers and by the accrual system.
+type Luhn struct {
+	Bounds
+}
+
+func (v Luhn) Valid(number string) bool {
+	if !v.digits(number) {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
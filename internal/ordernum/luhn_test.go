@@ -0,0 +1,58 @@
+package ordernum
+
+import (
+	"strconv"
+	"testing"
+)
+
+// referenceLuhn is the check the mart package used before this package existed.
+func referenceLuhn(cardNumber string) bool {
+	const (
+		digitsCount = 9
+		shift       = 2
+	)
+
+	digits := make([]int, len(cardNumber))
+	for i, char := range cardNumber {
+		digit, err := strconv.Atoi(string(char))
+		if err != nil {
+			return false
+		}
+		digits[i] = digit
+	}
+
+	for i := len(digits) - shift; i >= 0; i -= shift {
+		digits[i] *= shift
+		if digits[i] > digitsCount {
+			digits[i] -= digitsCount
+		}
+	}
+
+	sum := 0
+	for _, digit := range digits {
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
+
+func FuzzLuhn(f *testing.F) {
+	for _, seed := range []string{"", "0", "79927398713", "79927398710", "12345678903", "4561261212345467", "12a4", "١٢٣"} {
+		f.Add(seed)
+	}
+
+	bounds := Bounds{MinLen: 1, MaxLen: 64}
+	v := Luhn{bounds}
+	f.Fuzz(func(t *testing.T, number string) {
+		got := v.Valid(number)
+		if len(number) < bounds.MinLen || len(number) > bounds.MaxLen {
+			if got {
+				t.Fatalf("Valid(%q) = true for a number out of bounds", number)
+			}
+			return
+		}
+		if want := referenceLuhn(number); got != want {
+			t.Fatalf("Valid(%q) = %v, reference implementation says %v", number, got, want)
+		}
+	})
+}
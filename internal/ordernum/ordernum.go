@@ -0,0 +1,55 @@
+// Package ordernum validates order numbers with check-digit schemes.
+package ordernum
+
+import "fmt"
+
+// Validator reports whether an order number is well-formed and carries a correct check digit.
+type Validator interface {
+	Valid(number string) bool
+}
+
+const (
+	AlgorithmLuhn     = "luhn"
+	AlgorithmVerhoeff = "verhoeff"
+	AlgorithmDamm     = "damm"
+	AlgorithmISO7064  = "iso7064-mod97-10"
+)
+
+// Bounds limits the number of digits in an order number, check digits included.
+type Bounds struct {
+	MinLen int
+	MaxLen int
+}
+
+// New returns the validator for algorithm restricted to bounds.
+func New(algorithm string, bounds Bounds) (Validator, error) {
+	if bounds.MinLen < 1 || bounds.MaxLen < bounds.MinLen {
+		return nil, fmt.Errorf("invalid order number length bounds %d..%d", bounds.MinLen, bounds.MaxLen)
+	}
+
+	switch algorithm {
+	case AlgorithmLuhn:
+		return Luhn{bounds}, nil
+	case AlgorithmVerhoeff:
+		return Verhoeff{bounds}, nil
+	case AlgorithmDamm:
+		return Damm{bounds}, nil
+	case AlgorithmISO7064:
+		return ISO7064{bounds}, nil
+	default:
+		return nil, fmt.Errorf("unknown order number algorithm %q", algorithm)
+	}
+}
+
+// digits reports whether number consists of ASCII digits only and fits the bounds.
+func (b Bounds) digits(number string) bool {
+	if len(number) < b.MinLen || len(number) > b.MaxLen {
+		return false
+	}
+	for i := 0; i < len(number); i++ {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
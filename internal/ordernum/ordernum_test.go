@@ -0,0 +1,70 @@
+package ordernum
+
+import "testing"
+
+var testBounds = Bounds{MinLen: 1, MaxLen: 32}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name      string
+		validator Validator
+		number    string
+		want      bool
+	}{
+		{name: "luhn", validator: Luhn{testBounds}, number: "79927398713", want: true},
+		{name: "luhn single digit error", validator: Luhn{testBounds}, number: "79927398710"},
+		{name: "luhn non-digit", validator: Luhn{testBounds}, number: "7992739871a"},
+
+		{name: "verhoeff", validator: Verhoeff{testBounds}, number: "2363", want: true},
+		{name: "verhoeff long", validator: Verhoeff{testBounds}, number: "123451", want: true},
+		{name: "verhoeff single digit error", validator: Verhoeff{testBounds}, number: "2364"},
+		{name: "verhoeff transposition", validator: Verhoeff{testBounds}, number: "3263"},
+
+		{name: "damm", validator: Damm{testBounds}, number: "5724", want: true},
+		{name: "damm single digit error", validator: Damm{testBounds}, number: "5734"},
+		{name: "damm transposition", validator: Damm{testBounds}, number: "7524"},
+
+		{name: "iso 7064", validator: ISO7064{testBounds}, number: "79444", want: true},
+		// GB82 WEST 1234 5698 7654 32 with the letters and the country code moved to the end as IBAN does.
+		{name: "iso 7064 iban", validator: ISO7064{testBounds}, number: "3214282912345698765432161182", want: true},
+		{name: "iso 7064 single digit error", validator: ISO7064{testBounds}, number: "79454"},
+		{name: "iso 7064 transposition", validator: ISO7064{testBounds}, number: "97444"},
+		{name: "iso 7064 too short", validator: ISO7064{testBounds}, number: "01"},
+
+		{name: "too short", validator: Luhn{Bounds{MinLen: 12, MaxLen: 32}}, number: "79927398713"},
+		{name: "too long", validator: Luhn{Bounds{MinLen: 1, MaxLen: 10}}, number: "79927398713"},
+		{name: "empty", validator: Damm{testBounds}, number: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.validator.Valid(tt.number); got != tt.want {
+				t.Errorf("Valid(%q) = %v, want %v", tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidDoesNotAllocate(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		number    string
+	}{
+		{algorithm: AlgorithmLuhn, number: "79927398713"},
+		{algorithm: AlgorithmVerhoeff, number: "123451"},
+		{algorithm: AlgorithmDamm, number: "5724"},
+		{algorithm: AlgorithmISO7064, number: "3214282912345698765432161182"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			v, err := New(tt.algorithm, testBounds)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if allocs := testing.AllocsPerRun(100, func() { v.Valid(tt.number) }); allocs != 0 {
+				t.Errorf("Valid allocates %v times per call, want 0", allocs)
+			}
+		})
+	}
+}
@@ -2,27 +2,31 @@ package server
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/tiunovvv/gophermart/internal/config"
 	"github.com/tiunovvv/gophermart/internal/handler"
+	"github.com/tiunovvv/gophermart/internal/health"
+	"github.com/tiunovvv/gophermart/internal/metrics"
 	"go.uber.org/zap"
 )
 
-func InitServer(h *handler.Handler, cfg *config.Config, log *zap.Logger) *http.Server {
-	const (
-		maxHeaderBytes = 20
-		handlerTimeout = 5 * time.Second
-	)
+func InitServer(
+	h *handler.Handler,
+	cfg *config.Config,
+	log *zap.Logger,
+	m *metrics.Metrics,
+	hc *health.Checker,
+) *http.Server {
+	const maxHeaderBytes = 20
 
 	errorLog := zap.NewStdLog(log)
 
 	return &http.Server{
 		Addr:           cfg.RunAddress,
-		Handler:        h.InitRoutes(),
+		Handler:        h.InitRoutes(m, hc),
 		MaxHeaderBytes: 1 << maxHeaderBytes,
 		ErrorLog:       errorLog,
-		ReadTimeout:    handlerTimeout,
-		WriteTimeout:   handlerTimeout,
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
 	}
 }